@@ -27,15 +27,69 @@ type candidate struct {
 	Type    string
 	Class   decl_class
 	Package string
+
+	// doc comment, it's not filled by the autocompletion to keep it fast,
+	// see auto_complete_context.resolve_candidate
+	Doc string
+
+	// the declaration is deprecated, see the "deprecated" option
+	Deprecated bool
+
+	// text with ${N:placeholder} tab stops to insert instead of Name, empty
+	// if the candidate has no snippet
+	Snippet string
+
+	// relevance of the candidate, higher is better, see score_* constants
+	Score int
+
+	// additional changes to the file to apply when the candidate is
+	// accepted, e.g. an import of the package the candidate belongs to
+	Edits []text_edit
 }
 
+// Replaces Length bytes at the byte Offset of the file with Text. Offsets
+// refer to the file as it was passed to gocode.
+type text_edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// Candidates are ordered by their score, the score is a sum of the scope rank
+// of a declaration and the quality of the match against the typed prefix.
+const (
+	score_universe = 0
+	score_imported = 100
+	score_package  = 200
+	score_file     = 300
+	score_local    = 400 // minus the distance to the declaring scope
+
+	score_prefix            = 50 // case-sensitive prefix match
+	score_prefix_ignorecase = 40 // case-insensitive prefix match
+	score_fuzzy             = 30 // the best fuzzy match, see fuzzy_match
+	score_exact             = 20 // the name is equal to the typed prefix
+	score_exported          = 10
+
+	// the type of the candidate (or the result type of a function) is
+	// assignable to the type expected at the cursor
+	score_expected = 250
+)
+
 type out_buffers struct {
 	tmpbuf            *bytes.Buffer
 	candidates        []candidate
 	canonical_aliases map[string]string
 	ctx               *auto_complete_context
 	tmpns             map[string]bool
-	ignorecase        bool
+	matcher           matcher
+
+	// receiver placeholder for method expressions, e.g. T.Method(t, ...),
+	// empty if methods are called on a value
+	receiver string
+
+	// type expected at the cursor, see cursor_context
+	expected       ast.Expr
+	expected_scope *scope
 }
 
 func new_out_buffers(ctx *auto_complete_context) *out_buffers {
@@ -57,6 +111,12 @@ func (b *out_buffers) Len() int {
 func (b *out_buffers) Less(i, j int) bool {
 	x := b.candidates[i]
 	y := b.candidates[j]
+	if x.Deprecated != y.Deprecated && g_config.Deprecated == "demote" {
+		return y.Deprecated
+	}
+	if x.Score != y.Score {
+		return x.Score > y.Score
+	}
 	if x.Class == y.Class {
 		return x.Name < y.Name
 	}
@@ -70,25 +130,84 @@ func (b *out_buffers) Swap(i, j int) {
 func (b *out_buffers) append_decl(p, name, pkg string, decl *decl, class decl_class) {
 	c1 := !g_config.ProposeBuiltins && decl.scope == g_universe_scope && decl.name != "Error"
 	c2 := class != decl_invalid && decl.class != class
-	c3 := class == decl_invalid && !has_prefix(name, p, b.ignorecase)
+	score, matched := b.matcher.match(name, p)
+	c3 := class == decl_invalid && !matched
 	c4 := !decl.matches()
 	c5 := !check_type_expr(decl.typ)
+	c6 := decl.is_deprecated() && g_config.Deprecated == "hide"
 
-	if c1 || c2 || c3 || c4 || c5 {
+	if c1 || c2 || c3 || c4 || c5 || c6 {
 		return
 	}
 
 	decl.pretty_print_type(b.tmpbuf, b.canonical_aliases)
 	b.candidates = append(b.candidates, candidate{
-		Name:    name,
-		Type:    b.tmpbuf.String(),
-		Class:   decl.class,
-		Package: pkg,
+		Name:       name,
+		Type:       b.tmpbuf.String(),
+		Class:      decl.class,
+		Package:    pkg,
+		Snippet:    decl_call_snippet(name, decl, b.receiver),
+		Score:      score + b.expected_score(decl),
+		Deprecated: decl.is_deprecated(),
 	})
 	b.tmpbuf.Reset()
 }
 
-func (b *out_buffers) append_embedded(p string, decl *decl, pkg string, class decl_class) {
+// Returns score_expected if the declaration yields a value of the type
+// expected at the cursor: variables and constants of that type, as well as
+// functions returning it.
+func (b *out_buffers) expected_score(d *decl) int {
+	if b.expected == nil {
+		return 0
+	}
+	var t ast.Expr
+	var s *scope
+	switch d.class {
+	case decl_var, decl_const:
+		t, s = d.infer_type()
+	case decl_func:
+		ft, ok := d.typ.(*ast.FuncType)
+		if !ok || ft.Results == nil || func_return_type(ft, 1) != nil {
+			return 0
+		}
+		t, s = func_return_type(ft, 0), d.scope
+	default:
+		return 0
+	}
+	if !type_assignable(t, s, b.expected, b.expected_scope, b.canonical_aliases) {
+		return 0
+	}
+	return score_expected
+}
+
+func (b *out_buffers) append_label(p, name string) {
+	score, matched := b.matcher.match(name, p)
+	if !matched {
+		return
+	}
+	b.candidates = append(b.candidates, candidate{
+		Name:  name,
+		Class: decl_label,
+		Score: score_local + score,
+	})
+}
+
+func (b *out_buffers) append_keyword(p, keyword string) {
+	score, matched := b.matcher.match(keyword, p)
+	if !matched {
+		return
+	}
+	b.candidates = append(b.candidates, candidate{
+		Name:  keyword,
+		Class: decl_keyword,
+		Score: score_package + score,
+	})
+}
+
+// Appends fields and methods promoted from embedded types. 'ptr_methods' tells
+// whether methods with pointer receivers are in the method set, they are if
+// the value is addressable or if the type is embedded by pointer.
+func (b *out_buffers) append_embedded(p string, decl *decl, pkg string, class decl_class, ptr_methods bool) {
 	if decl.embedded == nil {
 		return
 	}
@@ -123,14 +242,19 @@ func (b *out_buffers) append_embedded(p string, decl *decl, pkg string, class de
 		typedecl.set_visited()
 		defer typedecl.clear_visited()
 
+		_, byptr := emb.(*ast.StarExpr)
+		ptr := ptr_methods || byptr
 		for _, c := range typedecl.children {
 			if _, has := b.tmpns[c.name]; has {
 				continue
 			}
-			b.append_decl(p, c.name, pkg, c, class)
 			b.tmpns[c.name] = true
+			if c.is_ptr_method() && !ptr {
+				continue
+			}
+			b.append_decl(p, c.name, pkg, c, class)
 		}
-		b.append_embedded(p, typedecl, pkg, class)
+		b.append_embedded(p, typedecl, pkg, class, ptr)
 	}
 
 	if first_level {
@@ -204,6 +328,20 @@ func (c *auto_complete_context) merge_decls() {
 	propagate_type_alias_methods(c.pkg)
 }
 
+// returns import paths used by all the files of the current package
+func (c *auto_complete_context) used_import_paths() map[string]bool {
+	used := make(map[string]bool)
+	for _, imp := range c.current.packages {
+		used[imp.path] = true
+	}
+	for _, other := range c.others {
+		for _, imp := range other.packages {
+			used[imp.path] = true
+		}
+	}
+	return used
+}
+
 func (c *auto_complete_context) make_decl_set(scope *scope) map[string]*decl {
 	set := make(map[string]*decl, len(c.pkg.entities)*2)
 	make_decl_set_recursive(set, scope)
@@ -220,10 +358,49 @@ func (c *auto_complete_context) get_candidates_from_set(set map[string]*decl, pa
 		if pkg, ok := c.pcache[value.name]; ok {
 			pkgname = pkg.import_name
 		}
+		n := len(b.candidates)
 		b.append_decl(partial, key, pkgname, value, class)
+		if len(b.candidates) > n {
+			b.candidates[n].Score += c.scope_score(key, value)
+		}
 	}
 }
 
+// Ranks the declaration by the scope it is visible from: locals (the closer
+// to the cursor, the better), file scope (imported packages), package scope
+// and finally universe scope.
+func (c *auto_complete_context) scope_score(name string, d *decl) int {
+	score := 0
+	if ast.IsExported(name) {
+		score += score_exported
+	}
+	distance := 0
+	for s := c.current.scope; s != nil; s = s.parent {
+		if _, ok := s.entities[name]; !ok {
+			distance++
+			continue
+		}
+		switch s {
+		case g_universe_scope:
+			return score + score_universe
+		case c.pkg:
+			if d.flags&decl_foreign != 0 {
+				return score + score_imported
+			}
+			return score + score_package
+		case c.current.filescope:
+			return score + score_file
+		}
+		// each new declaration inside of a function body opens a new
+		// scope, thus the distance tells how recent the declaration is
+		if distance >= score_local-score_file {
+			distance = score_local - score_file - 1
+		}
+		return score + score_local - distance
+	}
+	return score
+}
+
 func (c *auto_complete_context) get_candidates_from_decl_alias(cc cursor_context, class decl_class, b *out_buffers) {
 	if cc.decl.is_visited() {
 		return
@@ -268,6 +445,10 @@ func (c *auto_complete_context) get_candidates_from_decl(cc cursor_context, clas
 				continue
 			}
 		}
+		if decl.is_ptr_method() && !cc.ptr_methods {
+			// not in the method set of the value, e.g. T{}.M or m[k].M
+			continue
+		}
 		b.append_decl(cc.partial, decl.name, c.decl_package_import_path(decl), decl, class)
 	}
 	// propose all children of an underlying struct/interface type
@@ -280,7 +461,174 @@ func (c *auto_complete_context) get_candidates_from_decl(cc cursor_context, clas
 		}
 	}
 	// propose all children of its embedded types
-	b.append_embedded(cc.partial, cc.decl, c.decl_package_import_path(cc.decl), class)
+	b.append_embedded(cc.partial, cc.decl, c.decl_package_import_path(cc.decl), class, cc.ptr_methods)
+}
+
+var g_file_keywords = []string{
+	"const", "func", "import", "type", "var",
+}
+
+var g_body_keywords = []string{
+	"defer", "for", "go", "if", "return", "select", "switch",
+}
+
+var g_switch_keywords = []string{
+	"case", "default",
+}
+
+func (c *auto_complete_context) get_keyword_candidates(kp keyword_position, partial string, b *out_buffers) {
+	var keywords []string
+	switch kp {
+	case keyword_file:
+		keywords = g_file_keywords
+	case keyword_body:
+		keywords = g_body_keywords
+	case keyword_switch:
+		keywords = append(g_switch_keywords, g_body_keywords...)
+	}
+	for _, k := range keywords {
+		b.append_keyword(partial, k)
+	}
+}
+
+// Proposes labels the branch statement 'tok' can refer to: any label of the
+// function for goto, labels of the enclosing statements for break and only
+// labels of the enclosing loops for continue.
+func (c *auto_complete_context) get_label_candidates(tok token.Token, partial string, b *out_buffers) {
+	for _, l := range c.current.labels {
+		switch tok {
+		case token.BREAK:
+			if l.enclosing == token.ILLEGAL {
+				continue
+			}
+		case token.CONTINUE:
+			if l.enclosing != token.FOR {
+				continue
+			}
+		}
+		b.append_label(partial, l.name)
+	}
+}
+
+// Proposes constants of the expected type declared in the package of the
+// type, e.g. "time.Monday" in "switch d { case # }" if d is a time.Weekday.
+// Constants of the current package are in the scope anyway.
+func (c *auto_complete_context) get_typed_const_candidates(partial string, class decl_class, b *out_buffers) {
+	if _, ok := b.expected.(*ast.SelectorExpr); !ok {
+		return
+	}
+	tp := get_type_path(b.expected)
+	path := package_decl_path(lookup_pkg(tp, b.expected_scope))
+	pkg, ok := c.pcache[path]
+	if !ok {
+		return
+	}
+	alias := b.canonical_aliases[path]
+	if alias == "" || alias == "." || alias == "_" {
+		return
+	}
+	for _, d := range pkg.typed_consts(tp.name) {
+		n := len(b.candidates)
+		b.append_decl(partial, alias+"."+d.name, pkg.import_name, d, class)
+		if len(b.candidates) > n {
+			b.candidates[n].Score += score_imported
+		}
+	}
+}
+
+// Proposes types for a case clause of a type switch, only the ones which
+// implement the interface of the switch operand: types visible in the current
+// scope and exported types of the imported packages (or of the package before
+// the '.'). "*T" is proposed if only the pointer type implements the interface.
+func (c *auto_complete_context) get_type_case_candidates(cc cursor_context, b *out_buffers) {
+	if cc.decl != nil {
+		if cc.decl.class != decl_package {
+			return
+		}
+		for _, d := range cc.decl.children {
+			if ast.IsExported(d.name) {
+				b.append_type_case(cc.partial, d.name, c.decl_package_import_path(d), d, cc.type_case)
+			}
+		}
+		return
+	}
+
+	set := c.make_decl_set(c.current.scope)
+	for name, d := range set {
+		n := len(b.candidates)
+		b.append_type_case(cc.partial, name, "", d, cc.type_case)
+		if len(b.candidates) > n {
+			b.candidates[n].Score += c.scope_score(name, d)
+		}
+	}
+	for _, imp := range c.current.packages {
+		pkg, ok := c.pcache[imp.abspath]
+		if !ok || pkg.main == nil || imp.alias == "." || imp.alias == "_" {
+			continue
+		}
+		for _, d := range pkg.main.children {
+			if !ast.IsExported(d.name) {
+				continue
+			}
+			n := len(b.candidates)
+			b.append_type_case(cc.partial, imp.alias+"."+d.name, pkg.import_name, d, cc.type_case)
+			if len(b.candidates) > n {
+				b.candidates[n].Score += score_imported
+			}
+		}
+	}
+}
+
+func (b *out_buffers) append_type_case(p, name, pkg string, d, iface *decl) {
+	if d.class != decl_type || d == iface || !d.matches() {
+		return
+	}
+	if !g_config.ProposeBuiltins && d.scope == g_universe_scope {
+		return
+	}
+	if d.is_deprecated() && g_config.Deprecated == "hide" {
+		return
+	}
+	score, matched := b.matcher.match(name, p)
+	if !matched {
+		return
+	}
+	ok, ptr := decl_implements(d, iface)
+	if !ok {
+		return
+	}
+	if ptr {
+		name = "*" + name
+	}
+	d.pretty_print_type(b.tmpbuf, b.canonical_aliases)
+	b.candidates = append(b.candidates, candidate{
+		Name:       name,
+		Type:       b.tmpbuf.String(),
+		Class:      decl_type,
+		Package:    pkg,
+		Score:      score,
+		Deprecated: d.is_deprecated(),
+	})
+	b.tmpbuf.Reset()
+}
+
+// removes constants which are already used by the previous case clauses
+func (b *out_buffers) omit_case_values(values []string) {
+	if len(values) == 0 {
+		return
+	}
+	used := make(map[string]bool, len(values))
+	for _, v := range values {
+		used[v] = true
+	}
+	candidates := b.candidates[:0]
+	for _, c := range b.candidates {
+		if c.Class == decl_const && used[c.Name] {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	b.candidates = candidates
 }
 
 func (c *auto_complete_context) get_import_candidates(partial string, b *out_buffers) {
@@ -288,14 +636,15 @@ func (c *auto_complete_context) get_import_candidates(partial string, b *out_buf
 	resultSet := map[string]struct{}{}
 	for _, pkgdir := range pkgdirs {
 		// convert srcpath to pkgpath and get candidates
-		get_import_candidates_dir(pkgdir, filepath.FromSlash(partial), b.ignorecase, currentPackagePath, resultSet)
+		get_import_candidates_dir(pkgdir, filepath.FromSlash(partial), b.matcher, currentPackagePath, resultSet)
 	}
 	for k := range resultSet {
-		b.candidates = append(b.candidates, candidate{Name: k, Class: decl_import})
+		score, _ := b.matcher.match(k, partial)
+		b.candidates = append(b.candidates, candidate{Name: k, Class: decl_import, Score: score})
 	}
 }
 
-func get_import_candidates_dir(root, partial string, ignorecase bool, currentPackagePath string, r map[string]struct{}) {
+func get_import_candidates_dir(root, partial string, m matcher, currentPackagePath string, r map[string]struct{}) {
 	var fpath string
 	var match bool
 	if strings.HasSuffix(partial, "/") {
@@ -311,10 +660,10 @@ func get_import_candidates_dir(root, partial string, ignorecase bool, currentPac
 		if err != nil {
 			panic(err)
 		}
-		if match && !has_prefix(rel, partial, ignorecase) {
+		if _, ok := m.match(rel, partial); match && !ok {
 			continue
 		} else if fi[i].IsDir() {
-			get_import_candidates_dir(root, rel+string(filepath.Separator), ignorecase, currentPackagePath, r)
+			get_import_candidates_dir(root, rel+string(filepath.Separator), m, currentPackagePath, r)
 		} else {
 			ext := filepath.Ext(name)
 			if ext != ".a" {
@@ -329,14 +678,14 @@ func get_import_candidates_dir(root, partial string, ignorecase bool, currentPac
 	}
 }
 
-// returns three slices of the same length containing:
-// 1. apropos names
-// 2. apropos types (pretty-printed)
-// 3. apropos classes
-// and length of the part that should be replaced (if any)
-func (c *auto_complete_context) apropos(file []byte, filename string, cursor int) ([]candidate, int) {
+// Parses the currently edited file and updates caches of other files of the
+// package and of imported packages. The active function (the one the cursor
+// is in) is processed fully, so that its local declarations are visible.
+func (c *auto_complete_context) process_file(file []byte, filename string, cursor int) {
 	c.current.cursor = cursor
 	c.current.name = filename
+	c.current.buffer = file
+	c.current.semi = cursor
 
 	// Update caches and parse the current file.
 	// This process is quite complicated, because I was trying to design it in a
@@ -359,14 +708,23 @@ func (c *auto_complete_context) apropos(file []byte, filename string, cursor int
 	// block.
 	c.update_caches()
 
+	c.mark_deprecated_imports()
+}
+
+// returns three slices of the same length containing:
+// 1. apropos names
+// 2. apropos types (pretty-printed)
+// 3. apropos classes
+// and length of the part that should be replaced (if any)
+func (c *auto_complete_context) apropos(file []byte, filename string, cursor int) ([]candidate, int) {
+	c.process_file(file, filename, cursor)
+
 	// And we're ready to Go. ;)
 
 	b := new_out_buffers(c)
-	if g_config.IgnoreCase {
-		if *g_debug {
-			log.Printf("ignoring case sensitivity")
-		}
-		b.ignorecase = true
+	b.matcher = config_matcher()
+	if *g_debug && b.matcher != matcher_prefix {
+		log.Printf("using %q matcher", g_config.Matcher)
 	}
 
 	cc, ok := c.deduce_cursor_context(file, cursor)
@@ -377,21 +735,18 @@ func (c *auto_complete_context) apropos(file []byte, filename string, cursor int
 		}
 		cc.partial = ""
 	}
+	var unimported string // import path of the package resolved below
 	if !ok {
 		var d *decl
-		if ident, ok := cc.expr.(*ast.Ident); ok && g_config.UnimportedPackages {
-			p := resolveKnownPackageIdent(ident.Name, c.current.name, c.current.context)
-			if p != nil {
-				c.pcache[p.name] = p
-				d = p.main
-			}
-		}
+		d, unimported = c.resolve_unimported_package(cc)
 		if d == nil {
 			return nil, 0
 		}
 		cc.decl = d
 	}
 
+	b.expected, b.expected_scope = cc.expected, cc.expected_scope
+
 	class := decl_invalid
 	if g_config.ClassFiltering {
 		switch cc.partial {
@@ -408,39 +763,133 @@ func (c *auto_complete_context) apropos(file []byte, filename string, cursor int
 		}
 	}
 
-	if cc.decl_import {
+	if cc.branch != token.ILLEGAL {
+		c.get_label_candidates(cc.branch, cc.partial, b)
+		if cc.partial != "" && len(b.candidates) == 0 && b.matcher == matcher_prefix {
+			// as a fallback, try case insensitive approach
+			b.matcher = matcher_case_insensitive
+			c.get_label_candidates(cc.branch, cc.partial, b)
+		}
+	} else if cc.type_case != nil {
+		c.get_type_case_candidates(cc, b)
+		if cc.partial != "" && len(b.candidates) == 0 && b.matcher == matcher_prefix {
+			// as a fallback, try case insensitive approach
+			b.matcher = matcher_case_insensitive
+			c.get_type_case_candidates(cc, b)
+		}
+	} else if cc.decl_import {
 		c.get_import_candidates(cc.partial, b)
-		if cc.partial != "" && len(b.candidates) == 0 {
+		if cc.partial != "" && len(b.candidates) == 0 && b.matcher == matcher_prefix {
 			// as a fallback, try case insensitive approach
-			b.ignorecase = true
+			b.matcher = matcher_case_insensitive
 			c.get_import_candidates(cc.partial, b)
 		}
 	} else if cc.decl == nil {
 		// In case if no declaraion is a subject of completion, propose all:
 		set := c.make_decl_set(c.current.scope)
 		c.get_candidates_from_set(set, cc.partial, class, b)
-		if cc.partial != "" && len(b.candidates) == 0 {
+		if cc.partial != "" && len(b.candidates) == 0 && b.matcher == matcher_prefix {
 			// as a fallback, try case insensitive approach
-			b.ignorecase = true
+			b.matcher = matcher_case_insensitive
 			c.get_candidates_from_set(set, cc.partial, class, b)
 		}
+		if cc.switch_case {
+			c.get_typed_const_candidates(cc.partial, class, b)
+		}
+		if class == decl_invalid && cc.partial != "" {
+			// keywords and snippets are proposed only after the first
+			// typed letter, otherwise they clutter the list of identifiers
+			c.get_keyword_candidates(cc.keyword, cc.partial, b)
+			if cc.keyword == keyword_body || cc.keyword == keyword_switch {
+				c.get_statement_snippet_candidates(cc.partial, b)
+			}
+		}
 	} else {
+		if cc.decl.class == decl_type && cc.expr != nil {
+			// "T." is a method expression, the receiver becomes the first
+			// argument of a method call
+			if _, _, is_type := infer_type(cc.expr, c.current.scope, -1); is_type {
+				b.receiver = param_name_from_type(cc.expr)
+			}
+		}
 		c.get_candidates_from_decl(cc, class, b)
-		if cc.partial != "" && len(b.candidates) == 0 {
+		if cc.partial != "" && len(b.candidates) == 0 && b.matcher == matcher_prefix {
 			// as a fallback, try case insensitive approach
-			b.ignorecase = true
+			b.matcher = matcher_case_insensitive
 			c.get_candidates_from_decl(cc, class, b)
 		}
+		if cc.struct_field && class == decl_invalid {
+			c.append_fill_struct_snippet(cc, b)
+		}
 	}
 
+	if cc.switch_case {
+		b.omit_case_values(cc.case_values)
+	}
 	if len(b.candidates) == 0 {
 		return nil, 0
 	}
 
+	if unimported != "" {
+		// the package is not imported yet, accepting any of its members
+		// should add the import
+		if edit, ok := import_edit(file, unimported); ok {
+			for i := range b.candidates {
+				b.candidates[i].Edits = []text_edit{edit}
+			}
+		}
+	}
+
 	sort.Sort(b)
 	return b.candidates, partial
 }
 
+// Resolves the identifier before the '.' to a package which is not imported
+// yet, returns its declaration and import path. Works only if the
+// "unimported-packages" option is enabled.
+func (c *auto_complete_context) resolve_unimported_package(cc cursor_context) (*decl, string) {
+	ident, ok := cc.expr.(*ast.Ident)
+	if !ok || !g_config.UnimportedPackages {
+		return nil, ""
+	}
+	p := resolveKnownPackageIdent(ident.Name, c.current.name, c.current.context, c.used_import_paths())
+	if p == nil {
+		return nil, ""
+	}
+	c.pcache[p.name] = p
+	return p.main, p.import_name
+}
+
+// Returns the candidate 'name' proposed at the cursor with the details which
+// are too expensive to get for every candidate of the list, i.e. the doc
+// comment. Editors call it for the selected candidate only.
+func (c *auto_complete_context) resolve_candidate(file []byte, filename string, cursor int, name string) (candidate, error) {
+	c.process_file(file, filename, cursor)
+
+	cc, ok := c.deduce_cursor_context(file, cursor)
+	if !ok {
+		cc.decl, _ = c.resolve_unimported_package(cc)
+		if cc.decl == nil {
+			return candidate{}, fmt.Errorf("%q not found", name)
+		}
+	}
+	d := c.context_decl(cc, name)
+	if d == nil {
+		return candidate{}, fmt.Errorf("%q not found", name)
+	}
+
+	b := new_out_buffers(c)
+	d.pretty_print_type(b.tmpbuf, b.canonical_aliases)
+	return candidate{
+		Name:       name,
+		Type:       b.tmpbuf.String(),
+		Class:      d.class,
+		Package:    c.decl_package_import_path(d),
+		Doc:        c.decl_doc(d),
+		Deprecated: d.is_deprecated(),
+	}, nil
+}
+
 func update_packages(ps map[string]*package_file_cache) {
 	// initiate package cache update
 	done := make(chan bool)
@@ -715,7 +1164,10 @@ var g_decl_class_to_color = [...]string{
 	decl_var:          color_magenta,
 	decl_type:         color_cyan,
 	decl_func:         color_green,
+	decl_keyword:      color_blue,
+	decl_label:        color_yellow,
 	decl_package:      color_red,
+	decl_snippet:      color_blue,
 	decl_methods_stub: color_red,
 }
 
@@ -724,7 +1176,10 @@ var g_decl_class_to_string_status = [...]string{
 	decl_var:          "    var",
 	decl_type:         "   type",
 	decl_func:         "   func",
+	decl_keyword:      "keyword",
+	decl_label:        "  label",
 	decl_package:      "package",
+	decl_snippet:      "snippet",
 	decl_methods_stub: "   stub",
 }
 
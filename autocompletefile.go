@@ -13,7 +13,7 @@ func parse_decl_list(fset *token.FileSet, data []byte) ([]ast.Decl, error) {
 	var buf bytes.Buffer
 	buf.WriteString("package p;")
 	buf.Write(data)
-	file, err := parser.ParseFile(fset, "", buf.Bytes(), parser.AllErrors)
+	file, err := parser.ParseFile(fset, "", buf.Bytes(), parser.AllErrors|parser.ParseComments)
 	if err != nil {
 		return file.Decls, err
 	}
@@ -43,16 +43,39 @@ type auto_complete_file struct {
 	packages  []package_import
 	filescope *scope
 	scope     *scope
+	functype  *ast.FuncType // type of the function the cursor is in
+	funcbody  *ast.BlockStmt
+	labels    []label // labels visible at the cursor
+
+	// used to map positions to the original file buffer, see 'position'
+	buffer  []byte      // file contents without the semicolon hack
+	outer   *token.File // file without the ripped off declaration
+	rip_beg int         // offset of the ripped off declaration
+	rip_len int         // length of the ripped off declaration, 0 if none
+	semi    int         // offset of the inserted semicolon, -1 if none
+
+	// syntax errors of the last parse, located in the original file buffer
+	errors []diagnostic
 
 	cursor  int // for current file buffer only
 	fset    *token.FileSet
 	context *package_lookup_context
 }
 
+// a label visible at the cursor location
+type label struct {
+	name string
+
+	// kind of the labeled statement if the cursor is inside of it:
+	// token.FOR, token.SWITCH or token.SELECT, otherwise token.ILLEGAL
+	enclosing token.Token
+}
+
 func new_auto_complete_file(name string, context *package_lookup_context) *auto_complete_file {
 	p := new(auto_complete_file)
 	p.name = name
 	p.cursor = -1
+	p.semi = -1
 	p.fset = token.NewFileSet()
 	p.context = context
 	return p
@@ -63,19 +86,77 @@ func (f *auto_complete_file) offset(p token.Pos) int {
 	return f.fset.Position(p).Offset - fixlen
 }
 
+// Returns the location of 'p' in the original file buffer. The file is parsed
+// in two pieces: the file without the declaration the cursor is in and that
+// declaration prefixed with "package p;", also a semicolon is inserted at the
+// cursor location, all of that is undone here.
+func (f *auto_complete_file) position(p token.Pos) token.Position {
+	tf := f.fset.File(p)
+	if tf == nil {
+		return token.Position{}
+	}
+	return f.buffer_position(tf == f.outer, tf.Offset(p))
+}
+
+// Like 'position', but takes the offset in the file without the ripped off
+// declaration if 'outer' is true, otherwise in the declaration.
+func (f *auto_complete_file) buffer_position(outer bool, off int) token.Position {
+	const fixlen = len("package p;")
+	if f.buffer == nil {
+		return token.Position{}
+	}
+	if outer {
+		if f.rip_len > 0 && off >= f.rip_beg {
+			off += f.rip_len
+		}
+	} else {
+		off = f.rip_beg + off - fixlen
+	}
+	if f.semi != -1 && off > f.semi {
+		off--
+	}
+	if off < 0 || off > len(f.buffer) {
+		return token.Position{}
+	}
+	return offset_position(f.name, f.buffer, off)
+}
+
+// converts the byte offset in the 'data' to a position, columns are in bytes
+// as in go/token
+func offset_position(filename string, data []byte, off int) token.Position {
+	line_beg := bytes.LastIndexByte(data[:off], '\n') + 1
+	return token.Position{
+		Filename: filename,
+		Offset:   off,
+		Line:     bytes.Count(data[:off], []byte{'\n'}) + 1,
+		Column:   off - line_beg + 1,
+	}
+}
+
 // this one is used for current file buffer exclusively
 func (f *auto_complete_file) process_data(data []byte) {
 	cur, filedata, block := rip_off_decl(data, f.cursor)
-	file, err := parser.ParseFile(f.fset, "", filedata, parser.AllErrors)
+	file, err := parser.ParseFile(f.fset, "", filedata, parser.AllErrors|parser.ParseComments)
 	if err != nil && *g_debug {
 		log_parse_error("Error parsing input file (outer block)", err)
 	}
+	f.errors = nil
+	outer_err := err
 	f.package_name = package_name(file)
+	f.outer = f.fset.File(file.Package)
+	f.rip_beg, f.rip_len = 0, 0
+	if block != nil {
+		f.rip_beg, f.rip_len = f.cursor-cur, len(block)
+	}
 
 	f.decls = make(map[string]*decl)
 	f.packages = collect_package_imports(f.name, file.Decls, f.context)
 	f.filescope = new_scope(nil)
 	f.scope = f.filescope
+	f.functype = nil
+	f.funcbody = nil
+	f.labels = nil
+	f.add_syntax_errors(true, outer_err)
 
 	for _, d := range file.Decls {
 		anonymify_ast(d, 0, f.filescope)
@@ -83,7 +164,7 @@ func (f *auto_complete_file) process_data(data []byte) {
 
 	// process all top-level declarations
 	for _, decl := range file.Decls {
-		append_to_top_decls(f.decls, decl, f.scope)
+		append_to_top_decls(f.decls, decl, f.scope, f.position)
 	}
 	if block != nil {
 		// process local function as top-level declaration
@@ -91,13 +172,14 @@ func (f *auto_complete_file) process_data(data []byte) {
 		if err != nil && *g_debug {
 			log_parse_error("Error parsing input file (inner block)", err)
 		}
+		f.add_syntax_errors(false, err)
 
 		for _, d := range decls {
 			anonymify_ast(d, 0, f.filescope)
 		}
 
 		for _, decl := range decls {
-			append_to_top_decls(f.decls, decl, f.scope)
+			append_to_top_decls(f.decls, decl, f.scope, f.position)
 		}
 
 		// process function internals
@@ -115,6 +197,9 @@ func (f *auto_complete_file) process_decl_locals(decl ast.Decl) {
 		if f.cursor_in(t.Body) {
 			s := f.scope
 			f.scope = new_scope(f.scope)
+			f.functype = t.Type
+			f.funcbody = t.Body
+			f.labels = nil
 
 			f.process_field_list(t.Recv, s)
 			f.process_field_list(t.Type.Params, s)
@@ -145,6 +230,8 @@ func (f *auto_complete_file) process_decl(decl ast.Decl) {
 			if d == nil {
 				return
 			}
+			d.pos = f.position(name.Pos())
+			d.set_member_info(f.position)
 
 			f.scope.add_named_decl(d)
 		}
@@ -174,6 +261,9 @@ func (v *func_lit_visitor) Visit(node ast.Node) ast.Visitor {
 	if t, ok := node.(*ast.FuncLit); ok && v.ctx.cursor_in(t.Body) {
 		s := v.ctx.scope
 		v.ctx.scope = new_scope(v.ctx.scope)
+		v.ctx.functype = t.Type
+		v.ctx.funcbody = t.Body
+		v.ctx.labels = nil
 
 		v.ctx.process_field_list(t.Type.Params, s)
 		v.ctx.process_field_list(t.Type.Results, s)
@@ -219,8 +309,58 @@ func (f *auto_complete_file) process_stmt(stmt ast.Stmt) {
 	case *ast.SelectStmt:
 		f.process_select_stmt(t)
 	case *ast.LabeledStmt:
-		f.process_stmt(t.Stmt)
+		f.process_labeled_stmt(t)
+	}
+}
+
+// Labels have function scope, they are collected from all the blocks which
+// enclose the cursor, because goto can't jump into a block anyway. The label
+// is skipped if it belongs to the function which encloses the function
+// literal the cursor is in.
+func (f *auto_complete_file) process_labeled_stmt(a *ast.LabeledStmt) {
+	if a.Label.Name != "_" && f.in_func_body(a) {
+		f.labels = append(f.labels, label{
+			name:      a.Label.Name,
+			enclosing: f.enclosing_stmt_kind(a.Stmt),
+		})
+	}
+	f.process_stmt(a.Stmt)
+}
+
+func (f *auto_complete_file) in_func_body(n ast.Node) bool {
+	body := f.funcbody
+	if body == nil || f.offset(n.Pos()) <= f.offset(body.Lbrace) {
+		return false
+	}
+	return !body.Rbrace.IsValid() || f.offset(n.Pos()) < f.offset(body.Rbrace)
+}
+
+// returns the kind of the statement 's' if the cursor is inside of its body,
+// token.ILLEGAL otherwise
+func (f *auto_complete_file) enclosing_stmt_kind(s ast.Stmt) token.Token {
+	switch t := s.(type) {
+	case *ast.ForStmt:
+		if f.cursor_in(t.Body) {
+			return token.FOR
+		}
+	case *ast.RangeStmt:
+		if f.cursor_in(t.Body) {
+			return token.FOR
+		}
+	case *ast.SwitchStmt:
+		if f.cursor_in(t.Body) {
+			return token.SWITCH
+		}
+	case *ast.TypeSwitchStmt:
+		if f.cursor_in(t.Body) {
+			return token.SWITCH
+		}
+	case *ast.SelectStmt:
+		if f.cursor_in(t.Body) {
+			return token.SELECT
+		}
 	}
+	return token.ILLEGAL
 }
 
 func (f *auto_complete_file) process_select_stmt(a *ast.SelectStmt) {
@@ -241,9 +381,10 @@ func (f *auto_complete_file) process_select_stmt(a *ast.SelectStmt) {
 		if last_cursor_after.Comm != nil {
 			//if lastCursorAfter.Lhs != nil && lastCursorAfter.Tok == token.DEFINE {
 			if astmt, ok := last_cursor_after.Comm.(*ast.AssignStmt); ok && astmt.Tok == token.DEFINE {
-				vname := astmt.Lhs[0].(*ast.Ident).Name
-				v := new_decl_var(vname, nil, astmt.Rhs[0], -1, prevscope)
+				vname := astmt.Lhs[0].(*ast.Ident)
+				v := new_decl_var(vname.Name, nil, astmt.Rhs[0], -1, prevscope)
 				if v != nil {
+					v.pos = f.position(vname.Pos())
 					f.scope.add_named_decl(v)
 				}
 			}
@@ -268,8 +409,11 @@ func (f *auto_complete_file) process_type_switch_stmt(a *ast.TypeSwitchStmt) {
 		lhs := a.Lhs
 		rhs := a.Rhs
 		if lhs != nil && len(lhs) == 1 {
-			tvname := lhs[0].(*ast.Ident).Name
-			tv = new_decl_var(tvname, nil, rhs[0], -1, prevscope)
+			tvname := lhs[0].(*ast.Ident)
+			tv = new_decl_var(tvname.Name, nil, rhs[0], -1, prevscope)
+			if tv != nil {
+				tv.pos = f.position(tvname.Pos())
+			}
 		}
 	}
 
@@ -325,6 +469,7 @@ func (f *auto_complete_file) process_range_stmt(a *ast.RangeStmt) {
 		if t, ok := a.Key.(*ast.Ident); ok {
 			d := new_decl_var(t.Name, nil, a.X, 0, prevscope)
 			if d != nil {
+				d.pos = f.position(t.Pos())
 				d.flags |= decl_rangevar
 				f.scope.add_named_decl(d)
 			}
@@ -334,6 +479,7 @@ func (f *auto_complete_file) process_range_stmt(a *ast.RangeStmt) {
 			if t, ok := a.Value.(*ast.Ident); ok {
 				d := new_decl_var(t.Name, nil, a.X, 1, prevscope)
 				if d != nil {
+					d.pos = f.position(t.Pos())
 					d.flags |= decl_rangevar
 					f.scope.add_named_decl(d)
 				}
@@ -369,6 +515,7 @@ func (f *auto_complete_file) process_assign_stmt(a *ast.AssignStmt) {
 		if d == nil {
 			continue
 		}
+		d.pos = f.position(name.Pos())
 
 		f.scope.add_named_decl(d)
 	}
@@ -377,6 +524,13 @@ func (f *auto_complete_file) process_assign_stmt(a *ast.AssignStmt) {
 func (f *auto_complete_file) process_field_list(field_list *ast.FieldList, s *scope) {
 	if field_list != nil {
 		decls := ast_field_list_to_decls(field_list, decl_var, 0, s, false)
+		for _, field := range field_list.List {
+			for _, name := range field.Names {
+				if d, ok := decls[name.Name]; ok {
+					d.pos = f.position(name.Pos())
+				}
+			}
+		}
 		for _, d := range decls {
 			f.scope.add_named_decl(d)
 		}
@@ -416,5 +570,11 @@ func (f *auto_complete_file) cursor_in(block *ast.BlockStmt) bool {
 	if f.cursor > f.offset(block.Lbrace) && f.cursor <= f.offset(block.Rbrace) {
 		return true
 	}
+	// parser wasn't able to find the closing brace, happens when a statement
+	// before the cursor is incomplete, e.g. "if#", assume it's somewhere after
+	// the cursor
+	if !block.Rbrace.IsValid() && f.cursor > f.offset(block.Lbrace) {
+		return true
+	}
 	return false
 }
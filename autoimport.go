@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//-------------------------------------------------------------------------
+// Auto-import
+//
+// Candidates of packages resolved via the "unimported-packages" option carry
+// a text edit which adds the missing import to the file.
+//-------------------------------------------------------------------------
+
+// Returns an edit which adds the import 'path' to the source 'file'. The spec
+// is inserted into the last import block: standard library imports and the
+// other ones are kept in separate groups, specs within a group are sorted. A
+// single-line import is turned into a block, if there are no imports at all
+// an import declaration is added right after the package clause.
+func import_edit(file []byte, path string) (text_edit, bool) {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, "", file, parser.ImportsOnly)
+	if f == nil || f.Name == nil {
+		return text_edit{}, false
+	}
+	offset := func(p token.Pos) int {
+		return fset.Position(p).Offset
+	}
+	line := func(p token.Pos) int {
+		return fset.Position(p).Line
+	}
+
+	var last *ast.GenDecl
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			if import_spec_path(spec.(*ast.ImportSpec)) == path {
+				return text_edit{}, false
+			}
+		}
+		last = gd
+	}
+	quoted := strconv.Quote(path)
+
+	if last == nil {
+		eol := end_of_line(file, offset(f.Name.End()))
+		return text_edit{Offset: eol, Text: "\n\nimport " + quoted}, true
+	}
+
+	if !last.Lparen.IsValid() {
+		spec := last.Specs[0].(*ast.ImportSpec)
+		if import_spec_path(spec) == "C" {
+			// cgo preamble must stay attached to the import "C" alone
+			eol := end_of_line(file, offset(last.End()))
+			return text_edit{Offset: eol, Text: "\n\nimport " + quoted}, true
+		}
+
+		// import "fmt" -> import ( "fmt"; "path" )
+		specs := []string{string(file[offset(spec.Pos()):offset(spec.End())]), quoted}
+		paths := []string{import_spec_path(spec), path}
+		if import_path_less(paths[1], paths[0]) {
+			specs[0], specs[1] = specs[1], specs[0]
+			paths[0], paths[1] = paths[1], paths[0]
+		}
+		var buf bytes.Buffer
+		buf.WriteString("import (\n")
+		for i, s := range specs {
+			if i > 0 && is_std_import_path(paths[i-1]) != is_std_import_path(paths[i]) {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("\t" + s + "\n")
+		}
+		buf.WriteString(")")
+		start, end := offset(last.Pos()), offset(last.End())
+		return text_edit{Offset: start, Length: end - start, Text: buf.String()}, true
+	}
+
+	if len(last.Specs) == 0 {
+		return text_edit{Offset: offset(last.Lparen) + 1, Text: "\n\t" + quoted + "\n"}, true
+	}
+
+	// split the block into groups separated by blank lines
+	var groups [][]*ast.ImportSpec
+	for i, s := range last.Specs {
+		spec := s.(*ast.ImportSpec)
+		if i == 0 || line(spec.Pos())-line(last.Specs[i-1].End()) > 1 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], spec)
+	}
+	first := last.Specs[0].(*ast.ImportSpec)
+	indent := string(file[start_of_line(file, offset(first.Pos())):offset(first.Pos())])
+
+	// standard library imports go to the first group of them, the other
+	// ones go to the last group of non-standard imports
+	std := is_std_import_path(path)
+	var group []*ast.ImportSpec
+	for _, g := range groups {
+		if import_group_is_std(g) == std {
+			group = g
+			if std {
+				break
+			}
+		}
+	}
+	if group == nil {
+		if std {
+			return text_edit{Offset: offset(first.Pos()), Text: quoted + "\n\n" + indent}, true
+		}
+		lastspec := last.Specs[len(last.Specs)-1]
+		eol := end_of_line(file, offset(lastspec.End()))
+		return text_edit{Offset: eol, Text: "\n\n" + indent + quoted}, true
+	}
+
+	i := sort.Search(len(group), func(i int) bool {
+		return import_spec_path(group[i]) > path
+	})
+	if i < len(group) {
+		pos := offset(group[i].Pos())
+		indent = string(file[start_of_line(file, pos):pos])
+		return text_edit{Offset: pos, Text: quoted + "\n" + indent}, true
+	}
+	lastspec := group[len(group)-1]
+	pos := offset(lastspec.Pos())
+	indent = string(file[start_of_line(file, pos):pos])
+	eol := end_of_line(file, offset(lastspec.End()))
+	return text_edit{Offset: eol, Text: "\n" + indent + quoted}, true
+}
+
+// standard library imports go first, then the other ones sorted by path
+func import_path_less(a, b string) bool {
+	if sa, sb := is_std_import_path(a), is_std_import_path(b); sa != sb {
+		return sa
+	}
+	return a < b
+}
+
+func import_spec_path(spec *ast.ImportSpec) string {
+	path, _ := path_and_alias(spec)
+	return path
+}
+
+// standard library import paths don't have a dot in the first element
+func is_std_import_path(path string) bool {
+	if i := strings.Index(path, "/"); i != -1 {
+		path = path[:i]
+	}
+	return !strings.Contains(path, ".")
+}
+
+// a group is considered to be a standard library one if most of its imports are
+func import_group_is_std(group []*ast.ImportSpec) bool {
+	n := 0
+	for _, spec := range group {
+		if is_std_import_path(import_spec_path(spec)) {
+			n++
+		}
+	}
+	return n*2 > len(group)
+}
+
+func start_of_line(file []byte, offset int) int {
+	return bytes.LastIndexByte(file[:offset], '\n') + 1
+}
+
+func end_of_line(file []byte, offset int) int {
+	if i := bytes.IndexByte(file[offset:], '\n'); i != -1 {
+		return offset + i
+	}
+	return len(file)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func apply_edit(file []byte, e text_edit) string {
+	return string(file[:e.Offset]) + e.Text + string(file[e.Offset+e.Length:])
+}
+
+func TestImportEdit(t *testing.T) {
+	for _, test := range []struct {
+		src, path, want string
+	}{
+		// no imports
+		{"package p\n\nfunc f() {}\n", "strconv",
+			"package p\n\nimport \"strconv\"\n\nfunc f() {}\n"},
+		// a single import becomes a block
+		{"package p\n\nimport \"os\"\n", "fmt",
+			"package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n"},
+		{"package p\n\nimport \"os\"\n", "example.com/x",
+			"package p\n\nimport (\n\t\"os\"\n\n\t\"example.com/x\"\n)\n"},
+		// sorted within the group of the standard library
+		{"package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"example.com/x\"\n)\n", "io",
+			"package p\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"os\"\n\n\t\"example.com/x\"\n)\n"},
+		{"package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"example.com/x\"\n)\n", "strings",
+			"package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"strings\"\n\n\t\"example.com/x\"\n)\n"},
+		// and the other ones
+		{"package p\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/x\"\n)\n", "example.com/a",
+			"package p\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/a\"\n\t\"example.com/x\"\n)\n"},
+		// a new group
+		{"package p\n\nimport (\n\t\"example.com/x\"\n)\n", "fmt",
+			"package p\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/x\"\n)\n"},
+		{"package p\n\nimport ()\n", "fmt",
+			"package p\n\nimport (\n\t\"fmt\"\n)\n"},
+		// the cgo preamble stays attached to import "C"
+		{"package p\n\n// #include <stdio.h>\nimport \"C\"\n", "fmt",
+			"package p\n\n// #include <stdio.h>\nimport \"C\"\n\nimport \"fmt\"\n"},
+	} {
+		e, ok := import_edit([]byte(test.src), test.path)
+		if !ok {
+			t.Errorf("%q: no edit for %s", test.src, test.path)
+			continue
+		}
+		if got := apply_edit([]byte(test.src), e); got != test.want {
+			t.Errorf("%q: %s\ngot:\n%s\nwant:\n%s", test.src, test.path, got, test.want)
+		}
+	}
+
+	if e, ok := import_edit([]byte("package p\n\nimport x \"fmt\"\n"), "fmt"); ok {
+		t.Errorf("got the edit %+v for an imported package", e)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+//-------------------------------------------------------------------------
+// Doc comments
+//
+// Comments of declarations parsed from source are captured when the file is
+// parsed. Export data has no comments, for such declarations the source file
+// is parsed again and the comment is found by the position of the
+// declaration.
+//-------------------------------------------------------------------------
+
+// Returns the doc comment of the declaration 'd', an empty string if it has
+// none or if the source of the declaration isn't available.
+func (c *auto_complete_context) decl_doc(d *decl) string {
+	if d == nil {
+		return ""
+	}
+	if d.doc != "" || d.flags&decl_foreign == 0 || !d.pos.IsValid() {
+		return d.doc
+	}
+	pos := c.decl_position(d)
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, pos.Filename, nil, parser.ParseComments)
+	if file == nil {
+		return ""
+	}
+	return file_docs(file, fset)[doc_key{d.name, pos.Line}]
+}
+
+// Reports whether the doc comment has a paragraph starting with
+// "Deprecated: ", that's the convention for deprecated declarations.
+func is_deprecated_doc(doc string) bool {
+	for _, p := range strings.Split(doc, "\n\n") {
+		if strings.HasPrefix(p, "Deprecated: ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Marks deprecated members of the packages imported by the current file.
+// Export data has no comments, so the source files it refers to are scanned
+// in background, once per package load. The members are marked by the first
+// request after the scan is done, until then they are not flagged.
+func (c *auto_complete_context) mark_deprecated_imports() {
+	for _, imp := range c.current.packages {
+		p, ok := c.pcache[imp.abspath]
+		if !ok || p.main == nil {
+			continue
+		}
+		if p.deprecated == nil {
+			p.deprecated = c.scan_deprecated(p.main)
+			continue
+		}
+		p.deprecated.apply()
+	}
+}
+
+// deprecated members of a package found by the scan of its source files
+type deprecated_scan struct {
+	sync.Mutex
+	decls   map[deprecated_key]*decl // members and their methods and fields
+	found   map[deprecated_key]bool  // nil until the scan is done
+	applied bool
+}
+
+// members are identified by the source files and the lines of their
+// declarations, see doc_key
+type deprecated_key struct {
+	filename string
+	doc_key
+}
+
+// Starts the scan of the source files of the package 'main'. Only the files
+// mentioning "Deprecated:" are parsed.
+func (c *auto_complete_context) scan_deprecated(main *decl) *deprecated_scan {
+	s := &deprecated_scan{decls: make(map[deprecated_key]*decl)}
+	var filenames []string
+	add := func(d *decl) {
+		if !d.pos.IsValid() {
+			return
+		}
+		filename := c.decl_position(d).Filename
+		key := deprecated_key{filename, doc_key{d.name, d.pos.Line}}
+		if _, ok := s.decls[key]; ok {
+			return
+		}
+		if !contains_string(filenames, filename) {
+			filenames = append(filenames, filename)
+		}
+		s.decls[key] = d
+	}
+	for _, d := range main.children {
+		add(d)
+		for _, m := range d.children {
+			add(m)
+		}
+	}
+
+	go func() {
+		found := make(map[deprecated_key]bool)
+		for _, filename := range filenames {
+			for key := range deprecated_docs(filename) {
+				found[deprecated_key{filename, key}] = true
+			}
+		}
+
+		s.Lock()
+		s.found = found
+		s.Unlock()
+	}()
+	return s
+}
+
+// Flags the deprecated members if the scan is done and they aren't flagged
+// yet.
+func (s *deprecated_scan) apply() {
+	s.Lock()
+	found := s.found
+	s.Unlock()
+	if found == nil || s.applied {
+		return
+	}
+	s.applied = true
+	for key := range found {
+		if d := s.decls[key]; d != nil {
+			d.flags |= decl_deprecated
+		}
+	}
+}
+
+// Returns the declarations of the source file which have deprecated doc
+// comments.
+func deprecated_docs(filename string) map[doc_key]bool {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil || !bytes.Contains(data, []byte("Deprecated: ")) {
+		return nil
+	}
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, filename, data, parser.ParseComments)
+	if file == nil {
+		return nil
+	}
+	found := make(map[doc_key]bool)
+	for key, doc := range file_docs(file, fset) {
+		if is_deprecated_doc(doc) {
+			found[key] = true
+		}
+	}
+	return found
+}
+
+// Returns the text of the comment 'g' without comment markers and without
+// the surrounding blank lines.
+func comment_text(g *ast.CommentGroup) string {
+	if g == nil {
+		return ""
+	}
+	return strings.TrimSpace(g.Text())
+}
+
+// Returns the doc comment of a spec: its own one or, if the declaration isn't
+// grouped, the one of the declaration. A line comment is used as the last
+// resort, e.g.: "ID int // identifier".
+func spec_doc(gd *ast.GenDecl, doc, comment *ast.CommentGroup) string {
+	if doc == nil && !gd.Lparen.IsValid() {
+		doc = gd.Doc
+	}
+	if doc == nil {
+		doc = comment
+	}
+	return comment_text(doc)
+}
+
+// Returns the doc comment of the declaration 'd', see ast_decl_split, the
+// declaration has one spec at most.
+func ast_decl_doc(d ast.Decl) string {
+	switch t := d.(type) {
+	case *ast.FuncDecl:
+		return comment_text(t.Doc)
+	case *ast.GenDecl:
+		if len(t.Specs) != 1 {
+			return comment_text(t.Doc)
+		}
+		switch s := t.Specs[0].(type) {
+		case *ast.TypeSpec:
+			return spec_doc(t, s.Doc, s.Comment)
+		case *ast.ValueSpec:
+			return spec_doc(t, s.Doc, s.Comment)
+		}
+	}
+	return ""
+}
+
+func field_doc(f *ast.Field) string {
+	if f.Doc != nil {
+		return comment_text(f.Doc)
+	}
+	return comment_text(f.Comment)
+}
+
+// export data has only lines, so declarations are identified by their names
+// and lines
+type doc_key struct {
+	name string
+	line int
+}
+
+// Returns doc comments of all the declarations of the 'file', including
+// methods, struct fields and interface methods.
+func file_docs(file *ast.File, fset *token.FileSet) map[doc_key]string {
+	docs := make(map[doc_key]string)
+	add := func(name string, pos token.Pos, doc string) {
+		if doc != "" {
+			docs[doc_key{name, fset.Position(pos).Line}] = doc
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.FuncDecl:
+			add(t.Name.Name, t.Name.Pos(), comment_text(t.Doc))
+			// function bodies have no declarations of interest
+			return false
+		case *ast.GenDecl:
+			for _, spec := range t.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name, s.Name.Pos(), spec_doc(t, s.Doc, s.Comment))
+				case *ast.ValueSpec:
+					doc := spec_doc(t, s.Doc, s.Comment)
+					for _, id := range s.Names {
+						add(id.Name, id.Pos(), doc)
+					}
+				}
+			}
+		case *ast.Field:
+			doc := field_doc(t)
+			for _, id := range t.Names {
+				add(id.Name, id.Pos(), doc)
+			}
+			if len(t.Names) == 0 {
+				// embedded field
+				add(get_type_path(t.Type).name, t.Type.Pos(), doc)
+			}
+		}
+		return true
+	})
+	return docs
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecatedScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "x.go")
+	src := `package x
+
+// Old does it the old way.
+//
+// Deprecated: Use New instead.
+func Old() {}
+
+// New does it.
+func New() {}
+
+type T struct {
+	// Deprecated: Use B.
+	A int
+	B int
+}
+`
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the declarations as they come from export data, with lines only
+	main := new_decl("x", decl_package, nil)
+	decls := make(map[string]*decl)
+	for _, d := range []struct {
+		parent, name string
+		line         int
+	}{
+		{"", "Old", 6}, {"", "New", 9}, {"", "T", 11}, {"T", "A", 13}, {"T", "B", 14},
+	} {
+		decls[d.name] = new_decl(d.name, decl_func, nil)
+		decls[d.name].pos = token.Position{Filename: filename, Line: d.line}
+		if d.parent != "" {
+			decls[d.parent].add_child(decls[d.name])
+		} else {
+			main.add_child(decls[d.name])
+		}
+	}
+
+	if g_daemon == nil {
+		g_daemon = newDaemon()
+	}
+	s := g_daemon.autocomplete.scan_deprecated(main)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		s.Lock()
+		done := s.found != nil
+		s.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the scan is not done")
+		}
+	}
+	s.apply()
+
+	for name, want := range map[string]bool{"Old": true, "New": false, "T": false, "A": true, "B": false} {
+		if got := decls[name].is_deprecated(); got != want {
+			t.Errorf("%s: got deprecated %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestConfigSetDeprecated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	c := g_default_config
+	for _, value := range []string{"hide", "strike", "demote", ""} {
+		c.set_option("deprecated", value)
+	}
+	if c.Deprecated != "demote" {
+		t.Errorf("got %q, want demote", c.Deprecated)
+	}
+}
+
+func TestResolveCandidateDoc(t *testing.T) {
+	src := `package p
+
+// T is a thing.
+type T struct {
+	// Count counts.
+	Count int
+	Name  string // the name
+}
+
+// Reset resets it.
+//
+// Deprecated: Use New.
+func (t *T) Reset() {}
+
+const (
+	// A is a.
+	A = 1
+	B = 2 // b
+)
+
+// Limits.
+var limit, max = 1, 2
+
+func f(t T) {
+	%s#
+}
+`
+	for _, test := range []struct {
+		typed, name, doc string
+	}{
+		{"t.", "Count", "Count counts."},
+		// a line comment is used as the last resort
+		{"t.", "Name", "the name"},
+		{"t.", "Reset", "Reset resets it.\n\nDeprecated: Use New."},
+		{"", "T", "T is a thing."},
+		{"", "A", "A is a."},
+		{"", "B", "b"},
+		// the doc of an ungrouped declaration
+		{"", "max", "Limits."},
+		{"", "f", ""},
+	} {
+		f, cleanup := write_test_package(t, map[string]string{"a.go": fmt.Sprintf(src, test.typed)})
+		c, err := server_resolve_candidate(f.data, f.filename, f.cursor, test.name, test_context())
+		cleanup()
+		if err != nil {
+			t.Errorf("%s%s: %v", test.typed, test.name, err)
+			continue
+		}
+		if c.Name != test.name || c.Doc != test.doc {
+			t.Errorf("%s%s: got %s %q, want %q", test.typed, test.name, c.Name, c.Doc, test.doc)
+		}
+	}
+}
+
+func TestDeclDocForeign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "x.go")
+	src := "package x\n\n// Sum adds them up.\nfunc Sum(a, b int) int { return a + b }\n"
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// export data has no comments, the source is parsed
+	d := new_decl("Sum", decl_func, nil)
+	d.flags |= decl_foreign
+	d.pos = token.Position{Filename: filename, Line: 4}
+	if g_daemon == nil {
+		g_daemon = newDaemon()
+	}
+	if doc := g_daemon.autocomplete.decl_doc(d); doc != "Sum adds them up." {
+		t.Errorf("got %q", doc)
+	}
+}
+
+func TestDeprecatedTypeCase(t *testing.T) {
+	defer func(c config) { g_config = c }(g_config)
+	src := `package p
+
+type I interface{ M() }
+
+// Deprecated: Use New.
+type Old struct{}
+
+func (Old) M() {}
+
+type New struct{}
+
+func (New) M() {}
+
+func f(i I) {
+	switch i.(type) {
+	case #
+	}
+}
+`
+	for _, test := range []struct {
+		option, want string
+	}{
+		{"show", "New Old(deprecated)"},
+		{"hide", "New"},
+	} {
+		g_config.Deprecated = test.option
+		f, cleanup := write_test_package(t, map[string]string{"a.go": src})
+		cands, _ := server_auto_complete(f.data, f.filename, f.cursor, test_context())
+		cleanup()
+		var got []string
+		for _, c := range cands {
+			if c.Name == "New" || c.Name == "Old" {
+				if c.Deprecated {
+					got = append(got, c.Name+"(deprecated)")
+				} else {
+					got = append(got, c.Name)
+				}
+			}
+		}
+		if s := strings.Join(got, " "); s != test.want {
+			t.Errorf("%s: got %s, want %s", test.option, s, test.want)
+		}
+	}
+}
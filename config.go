@@ -31,7 +31,9 @@ type config struct {
 	UnimportedPackages bool   `json:"unimported-packages"`
 	Partials           bool   `json:"partials"`
 	IgnoreCase         bool   `json:"ignore-case"`
+	Matcher            string `json:"matcher"`
 	ClassFiltering     bool   `json:"class-filtering"`
+	Deprecated         string `json:"deprecated"`
 }
 
 var g_config_desc = map[string]string{
@@ -43,10 +45,19 @@ var g_config_desc = map[string]string{
 	"force-debug-output":  "If is not empty, gocode will forcefully redirect the logging into that file. Also forces enabling of the debug mode on the server side.",
 	"package-lookup-mode": "If set to {go}, use standard Go package lookup rules. If set to {gb}, use gb-specific lookup rules. See {https://github.com/constabulary/gb} for details.",
 	"close-timeout":       "If there have been no completion requests after this number of seconds, the gocode process will terminate. Default is 30 minutes.",
-	"unimported-packages": "If set to {true}, gocode will try to import certain known packages automatically for identifiers which cannot be resolved otherwise. Currently only a limited set of standard library packages is supported.",
+	"unimported-packages": "If set to {true}, gocode will try to import packages automatically for identifiers which cannot be resolved otherwise. Packages of the standard library, GOPATH, the module cache and the current module are indexed in background. If several packages have the same name, the ones imported by the current package, then the standard library ones, then the ones with shorter import paths are preferred.",
 	"partials":            "If set to {false}, gocode will not filter autocompletion results based on entered prefix before the cursor. Instead it will return all available autocompletion results viable for a given context. Whether this option is set to {true} or {false}, gocode will return a valid prefix length for output formats which support it. Setting this option to a non-default value may result in editor misbehaviour.",
 	"ignore-case":         "If set to {true}, gocode will perform case-insensitive matching when doing prefix-based filtering.",
+	"matcher":             "Defines how the entered prefix is matched against candidates. If set to {prefix}, candidates must start with the prefix. If set to {case-insensitive}, the case is ignored. If set to {fuzzy}, the letters of the prefix must appear in a candidate in the same order, e.g. {rdall} matches {ReadAll} and {NRW} matches {NewReadWriter}. Candidates are still ordered by their scope and by whether their type is the one expected at the cursor first, among the candidates of the same scope prefix matches and matches at word boundaries rank higher.",
 	"class-filtering":     "Enables or disables gocode's feature where it performs class-based filtering if partial input matches corresponding class keyword: const, var, type, func, package.",
+	"deprecated":          "Defines what to do with candidates whose doc comment has a paragraph starting with {Deprecated: }. If set to {show}, they are only flagged as deprecated. If set to {demote}, they are also proposed after all the other candidates. If set to {hide}, they are not proposed at all. Source files of imported packages are scanned for such comments in background, their candidates are flagged once the scan is done.",
+}
+
+// values the string options with a fixed set of choices accept, other values
+// are ignored
+var g_config_values = map[string][]string{
+	"matcher":    {"prefix", "case-insensitive", "fuzzy"},
+	"deprecated": {"show", "demote", "hide"},
 }
 
 var g_default_config = config{
@@ -60,7 +71,9 @@ var g_default_config = config{
 	UnimportedPackages: false,
 	Partials:           true,
 	IgnoreCase:         false,
+	Matcher:            "prefix",
 	ClassFiltering:     true,
+	Deprecated:         "show",
 }
 var g_config = g_default_config
 
@@ -79,6 +92,19 @@ var g_string_to_bool = map[string]bool{
 	"0":     false,
 }
 
+func valid_value(name, value string) bool {
+	values, ok := g_config_values[name]
+	if !ok {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func set_value(v reflect.Value, value string) {
 	switch t := v; t.Kind() {
 	case reflect.Bool:
@@ -145,7 +171,9 @@ func (this *config) set_option(name, value string) string {
 		v := str.Field(i)
 		nm := typ.Field(i).Tag.Get("json")
 		if nm == name {
-			set_value(v, value)
+			if valid_value(name, value) {
+				set_value(v, value)
+			}
 			list_value(v, name, buf)
 		}
 	}
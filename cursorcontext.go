@@ -7,6 +7,7 @@ import (
 	"go/scanner"
 	"go/token"
 	"log"
+	"strings"
 )
 
 type cursor_context struct {
@@ -15,12 +16,54 @@ type cursor_context struct {
 	struct_field bool
 	decl_import  bool
 
+	// if struct_field is true, names of the fields already present in the
+	// struct literal
+	struct_keys []string
+
+	// syntactic position of the cursor, used for keyword completion when
+	// there is no declaration to complete
+	keyword keyword_position
+
+	// token.BREAK, token.CONTINUE or token.GOTO if the cursor is at the label
+	// of a branch statement
+	branch token.Token
+
+	// if switch_case is true, the cursor is in a case clause of an expression
+	// switch, the expected type is the type of the switch tag and case_values
+	// are the values of the previous case clauses
+	switch_case bool
+	case_values []string
+
+	// if the cursor is in a case clause of a type switch, the interface type
+	// of the switch operand, the cases are types implementing it
+	type_case *decl
+
+	// type expected at the cursor location, e.g. the type of a parameter if
+	// the cursor is at a function call argument, nil if unknown
+	expected       ast.Expr
+	expected_scope *scope
+
+	// whether methods with pointer receivers are in the method set of the
+	// expression before the '.', i.e. it's a pointer or an addressable value
+	ptr_methods bool
+
 	// store expression that was supposed to be deduced to "decl", however
 	// if decl is nil, then deduction failed, we could try to resolve it to
 	// unimported package instead
 	expr ast.Expr
 }
 
+// keyword_position describes what kind of statement or declaration may start
+// at the cursor location
+type keyword_position int
+
+const (
+	keyword_none   keyword_position = iota
+	keyword_file                    // top-level declaration
+	keyword_body                    // statement inside a function body
+	keyword_switch                  // statement or clause inside a switch/select block
+)
+
 type token_iterator struct {
 	tokens      []token_item
 	token_index int
@@ -114,56 +157,243 @@ func (this *token_iterator) skip_to_left_curly() bool {
 	return this.skip_to_left(token.LBRACE, token.RBRACE)
 }
 
-func (ti *token_iterator) extract_type_alike() string {
-	if ti.token().tok != token.IDENT { // not Foo, return nothing
-		return ""
+// Figure out what kind of statement may start right after the current token.
+// Examples (# - the cursor):
+//   package p; fu#                  // keyword_file
+//   func f() { x := 1; re#          // keyword_body
+//   switch x { case 1: foo(); de#   // keyword_switch
+//   x := T{Fi#                      // keyword_none
+func (ti *token_iterator) keyword_position() keyword_position {
+	colon := ti.token().tok == token.COLON
+	switch ti.token().tok {
+	case token.SEMICOLON, token.COLON:
+	case token.LBRACE:
+		return ti.block_keyword_position()
+	default:
+		// not at the beginning of a statement
+		return keyword_none
 	}
-	b := ti.token().literal()
-	if !ti.go_back() { // just Foo
-		return b
+
+	// look for the innermost enclosing bracket
+	for ti.go_back() {
+		switch ti.token().tok {
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if !ti.skip_to_balanced_pair() {
+				return keyword_none
+			}
+		case token.LPAREN, token.LBRACK:
+			return keyword_none
+		case token.LBRACE:
+			return ti.block_keyword_position()
+		}
 	}
-	if ti.token().tok != token.PERIOD { // not .Foo, return Foo
-		return b
+	if colon {
+		// labels and case clauses are never at the top level
+		return keyword_none
 	}
-	if !ti.go_back() { // just .Foo, return Foo (best choice recovery)
-		return b
+	return keyword_file
+}
+
+// When the cursor is at the '{', look at the tokens before it and figure out
+// whether it opens a function body, a switch/select block or something else
+// (struct type, composite literal).
+func (ti *token_iterator) block_keyword_position() keyword_position {
+	if !ti.go_back() {
+		return keyword_none
 	}
-	if ti.token().tok != token.IDENT { // not lib.Foo, return Foo
-		return b
+	switch ti.token().tok {
+	case token.LBRACE, token.SEMICOLON, token.COLON:
+		// bare block: { {#} }
+		return keyword_body
+	}
+	for {
+		switch ti.token().tok {
+		case token.FUNC, token.IF, token.ELSE, token.FOR:
+			return keyword_body
+		case token.SWITCH, token.SELECT:
+			return keyword_switch
+		case token.STRUCT, token.INTERFACE, token.MAP:
+			return keyword_none
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if !ti.skip_to_balanced_pair() {
+				return keyword_none
+			}
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			return keyword_none
+		case token.SEMICOLON:
+			// explicit semicolons are part of the statement header, e.g.:
+			// switch x := f(); x {
+			if ti.token().lit == "\n" {
+				return keyword_none
+			}
+		}
+		if !ti.go_back() {
+			return keyword_none
+		}
 	}
-	out := ti.token().literal() + "." + b // lib.Foo
-	ti.go_back()
-	return out
 }
 
-// Extract the type expression right before the enclosing curly bracket block.
-// Examples (# - the cursor):
-//   &lib.Struct{Whatever: 1, Hel#} // returns "lib.Struct"
-//   X{#}                           // returns X
-// The idea is that we check if this type expression is a type and it is, we
-// can apply special filtering for autocompletion results.
-// Sadly, this doesn't cover anonymous structs.
-func (ti *token_iterator) extract_struct_type() string {
-	if !ti.skip_to_left_curly() {
-		return ""
+// When the cursor is inside of a struct literal, collect the keys of its
+// elements. Examples (# - the cursor):
+//   T{A: 1, B: f(x, y), #} // returns A, B
+func (ti *token_iterator) struct_literal_keys() []string {
+	var keys []string
+	for {
+		switch ti.token().tok {
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if !ti.skip_to_balanced_pair() {
+				return nil
+			}
+		case token.LBRACE:
+			return keys
+		case token.COLON:
+			if ti.token_index > 0 {
+				if prev := ti.tokens[ti.token_index-1]; prev.tok == token.IDENT {
+					keys = append(keys, prev.lit)
+				}
+			}
+		}
+		if !ti.go_back() {
+			return nil
+		}
 	}
-	if !ti.go_back() {
-		return ""
+}
+
+// When the cursor is in the expression list of a case clause of an expression
+// switch, returns the switch tag and the values of the case clauses before the
+// cursor. Examples (# - the cursor):
+//   switch d { case Mon: f(); case Tue, #   // returns "d", [Mon Tue]
+//   switch x := f(); x.Kind { case #        // returns "x.Kind", []
+func (ti *token_iterator) switch_case_values() (string, []string, bool) {
+	// find the case keyword of the current clause
+	for ti.token().tok != token.CASE {
+		switch tok := ti.token().tok; tok {
+		case token.RPAREN, token.RBRACK:
+			if !ti.skip_to_balanced_pair() {
+				return "", nil, false
+			}
+		case token.SEMICOLON, token.COLON, token.LPAREN, token.LBRACK,
+			token.LBRACE, token.RBRACE:
+			return "", nil, false
+		default:
+			if tok.IsKeyword() {
+				return "", nil, false
+			}
+		}
+		if !ti.go_back() {
+			return "", nil, false
+		}
+	}
+
+	// find the switch header: switch [init;] tag {
+	if !ti.go_back() || !ti.skip_to_left_curly() {
+		return "", nil, false
 	}
-	if ti.token().tok == token.LBRACE { // Foo{#{}}
+	body := ti.token_index
+	for ti.token().tok != token.SWITCH {
 		if !ti.go_back() {
-			return ""
+			return "", nil, false
+		}
+		switch ti.token().tok {
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if !ti.skip_to_balanced_pair() {
+				return "", nil, false
+			}
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			return "", nil, false
+		case token.SEMICOLON:
+			if ti.token().lit == "\n" {
+				return "", nil, false
+			}
+		}
+	}
+	start, depth := ti.token_index+1, 0
+	for i := start; i < body; i++ {
+		switch ti.tokens[i].tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+		case token.SEMICOLON:
+			if depth == 0 {
+				start = i + 1
+			}
 		}
-	} else if ti.token().tok == token.COMMA { // Foo{abc,#{}}
-		return ti.extract_struct_type()
 	}
-	typ := ti.extract_type_alike()
-	if typ == "" {
+	tag := token_items_to_string(ti.tokens[start:body])
+	if tag == "" {
+		return "", nil, false
+	}
+
+	// collect the values of the case clauses, nested blocks are skipped
+	var values []string
+	var value []token_item
+	in_case := false
+	depth = 0
+	for _, t := range ti.tokens[body+1:] {
+		switch t.tok {
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+		}
+		switch {
+		case depth == 0 && t.tok == token.CASE:
+			in_case = true
+		case depth == 0 && in_case && (t.tok == token.COMMA || t.tok == token.COLON):
+			if len(value) > 0 {
+				values = append(values, token_items_to_string(value))
+			}
+			value = nil
+			in_case = t.tok == token.COMMA
+		case in_case:
+			value = append(value, t)
+		}
+		switch t.tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		}
+	}
+	return tag, values, true
+}
+
+// When the cursor is at the '{' of a composite literal, extract the literal
+// type expression right before it. Examples (# - the cursor):
+//   &lib.Struct{#          // returns "lib.Struct"
+//   []map[string]*T{#      // returns "[]map[string]*T"
+//   func f() T {#          // returns "", it's a function body
+// The token iterator is left at the first token of the type expression.
+func (ti *token_iterator) extract_composite_type() string {
+	end := ti.token_index
+	first := end
+	prev := token.LBRACE
+loop:
+	for ti.go_back() {
+		switch tok := ti.token().tok; tok {
+		case token.IDENT:
+			if prev == token.IDENT {
+				break loop
+			}
+		case token.PERIOD, token.MUL, token.MAP, token.CHAN:
+		case token.RBRACK:
+			if !ti.skip_to_balanced_pair() {
+				return ""
+			}
+		default:
+			break loop
+		}
+		prev = ti.token().tok
+		first = ti.token_index
+	}
+	if first == end {
 		return ""
 	}
-	if ti.token().tok == token.RPAREN || ti.token().tok == token.MUL {
+
+	// function result types look exactly like composite literal types:
+	// func f() *T {, the same goes for pointer types: x * T{
+	if ti.token().tok == token.RPAREN || ti.tokens[first].tok == token.MUL {
 		return ""
 	}
+	typ := token_items_to_string(ti.tokens[first:end])
+	ti.token_index = first
 	return typ
 }
 
@@ -194,12 +424,18 @@ func (ti *token_iterator) extract_struct_type() string {
 // Of course there are also slightly more complicated rules for brackets:
 //   ident{}.ident()[5][4](), etc.
 func (this *token_iterator) extract_go_expr() string {
+	return this.extract_go_expr_before(this.token().tok)
+}
+
+// Same as extract_go_expr, but the token under the cursor is treated as if it
+// was 'next', e.g. token.PERIOD allows to extract the left operand of '='.
+func (this *token_iterator) extract_go_expr_before(next token.Token) string {
 	orig := this.token_index
 
 	// Contains the type of the previously scanned token (initialized with
 	// the token right under the cursor). This is the token to the *right* of
 	// the current one.
-	prev := this.token().tok
+	prev := next
 loop:
 	for {
 		if !this.go_back() {
@@ -269,18 +505,91 @@ func (c *auto_complete_context) deduce_cursor_decl(iter *token_iterator) (*decl,
 	return expr_to_decl(expr, c.current.scope), expr
 }
 
-// try to find and extract the surrounding struct literal type
+// Reports whether methods with pointer receivers can be called on the
+// expression 'e': it's a pointer, an addressable value or its type is unknown.
+// Types aren't filtered: the candidates after "T." serve the method
+// expressions of both T and *T.
+func (c *auto_complete_context) has_ptr_methods(e ast.Expr) bool {
+	t, _, is_type := infer_type(e, c.current.scope, -1)
+	if t == nil || is_type {
+		return true
+	}
+	for {
+		p, ok := t.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		t = p.X
+	}
+	if _, ok := t.(*ast.StarExpr); ok {
+		return true
+	}
+	return is_addressable(e, c.current.scope)
+}
+
+// Reports whether the value of the expression 'e' is addressable: variables,
+// pointer indirections, slice elements, fields and elements of addressable
+// structs and arrays. Map elements, function call results, composite literals
+// and conversions are not.
+func is_addressable(e ast.Expr, s *scope) bool {
+	switch t := e.(type) {
+	case *ast.ParenExpr:
+		return is_addressable(t.X, s)
+	case *ast.Ident:
+		d := s.lookup(t.Name)
+		return d == nil || d.class == decl_var
+	case *ast.StarExpr:
+		return true
+	case *ast.SelectorExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			if d := s.lookup(id.Name); d != nil && d.class == decl_package {
+				// package-level variable
+				return true
+			}
+		}
+		xt, xs, _ := infer_type(t.X, s, -1)
+		if xt == nil {
+			return true
+		}
+		if pt, _ := advance_to_type(star_predicate, xt, xs); pt != nil {
+			// implicit indirection of a pointer to struct
+			return true
+		}
+		return is_addressable(t.X, s)
+	case *ast.IndexExpr:
+		xt, xs, _ := infer_type(t.X, s, -1)
+		if xt == nil {
+			return true
+		}
+		xt, _ = advance_to_type(index_predicate, xt, xs)
+		switch xt := xt.(type) {
+		case *ast.ArrayType:
+			if xt.Len == nil {
+				return true
+			}
+			return is_addressable(t.X, s)
+		case *ast.Ellipsis:
+			return true
+		case *ast.MapType:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// try to find and extract the surrounding struct literal type, the literal
+// can be nested, with its type elided:
+//   []T{{#}}, map[string]T{"k": {#}}, Outer{Inner: {#}}
 func (c *auto_complete_context) deduce_struct_type_decl(iter *token_iterator) *decl {
-	typ := iter.extract_struct_type()
-	if typ == "" {
+	if !iter.skip_to_left_curly() {
 		return nil
 	}
-
-	expr, err := parser.ParseExpr(typ)
-	if err != nil {
+	typ, scope := c.deduce_composite_literal_type(iter, 0)
+	if typ == nil {
 		return nil
 	}
-	decl := type_to_decl(expr, c.current.scope)
+	decl := type_to_decl(typ, scope)
 	if decl == nil {
 		return nil
 	}
@@ -288,6 +597,9 @@ func (c *auto_complete_context) deduce_struct_type_decl(iter *token_iterator) *d
 	// we allow only struct types here, but also support type aliases
 	if decl.is_alias() {
 		dd := decl.type_dealias()
+		if dd == nil {
+			return nil
+		}
 		if _, ok := dd.typ.(*ast.StructType); !ok {
 			return nil
 		}
@@ -297,6 +609,311 @@ func (c *auto_complete_context) deduce_struct_type_decl(iter *token_iterator) *d
 	return decl
 }
 
+// maximum nesting level of composite literals with elided types
+const max_composite_literal_depth = 16
+
+// When the cursor is at the '{' of a composite literal, figure out its type.
+// If the type is elided, it is derived from the enclosing literal: the element
+// type of a slice, an array or a map, or the type of a struct field. Returns
+// the type expression and the scope where it makes sense.
+func (c *auto_complete_context) deduce_composite_literal_type(iter *token_iterator, depth int) (ast.Expr, *scope) {
+	if depth > max_composite_literal_depth || !iter.go_back() {
+		return nil, nil
+	}
+
+	switch iter.token().tok {
+	case token.COLON:
+		// Key: {#}
+		if !iter.go_back() {
+			return nil, nil
+		}
+		key := iter.token()
+		if !iter.skip_to_left_curly() {
+			return nil, nil
+		}
+		parent, s := c.deduce_composite_literal_type(iter, depth+1)
+		if parent == nil {
+			return nil, nil
+		}
+		u, us := advance_to_type(composite_predicate, parent, s)
+		switch t := u.(type) {
+		case *ast.StructType:
+			if key.tok != token.IDENT {
+				return nil, nil
+			}
+			if d := type_to_decl(parent, s); d != nil {
+				field := d.find_child_and_in_embedded(key.lit)
+				if field == nil || field.class != decl_var {
+					return nil, nil
+				}
+				return field.typ, field.scope
+			}
+			// anonymous struct type
+			for _, field := range t.Fields.List {
+				for _, name := range field.Names {
+					if name.Name == key.lit {
+						return field.Type, us
+					}
+				}
+			}
+		case *ast.MapType:
+			return t.Value, us
+		case *ast.ArrayType:
+			return t.Elt, us
+		}
+	case token.COMMA, token.LBRACE:
+		// {{#}} or {{...}, {#}}
+		if !iter.skip_to_left_curly() {
+			return nil, nil
+		}
+		parent, s := c.deduce_composite_literal_type(iter, depth+1)
+		if parent == nil {
+			return nil, nil
+		}
+		u, us := advance_to_type(composite_predicate, parent, s)
+		switch t := u.(type) {
+		case *ast.ArrayType:
+			return t.Elt, us
+		case *ast.MapType:
+			return t.Key, us
+		}
+	default:
+		iter.token_index++
+		typ := iter.extract_composite_type()
+		if typ == "" {
+			return nil, nil
+		}
+		expr, err := parser.ParseExpr(typ)
+		if err != nil {
+			return nil, nil
+		}
+		return expr, c.current.scope
+	}
+	return nil, nil
+}
+
+// If the operand the cursor is at is a value of a case clause of an expression
+// switch, the type of the switch tag is expected there. In a type switch the
+// interface type of the operand is stored instead. 'iter' is at the token
+// right before the operand, 'qualifier' is the package name the operand is
+// qualified with (if any), the values of the previous case clauses are stored
+// relative to it, e.g. "time.Monday" becomes "Monday".
+func (c *auto_complete_context) deduce_switch_case(iter token_iterator, qualifier string, cc *cursor_context) {
+	tag, values, ok := iter.switch_case_values()
+	if !ok {
+		return
+	}
+	if strings.HasSuffix(tag, ".(type)") {
+		cc.type_case = c.deduce_type_switch_interface(tag)
+		return
+	}
+	expr, err := parser.ParseExpr(tag)
+	if err != nil {
+		return
+	}
+	t, s, is_type := infer_type(expr, c.current.scope, -1)
+	if t == nil || is_type {
+		return
+	}
+	cc.switch_case = true
+	cc.expected, cc.expected_scope = t, s
+	for _, v := range values {
+		if qualifier != "" {
+			if !strings.HasPrefix(v, qualifier+".") {
+				continue
+			}
+			v = v[len(qualifier)+1:]
+		}
+		cc.case_values = append(cc.case_values, v)
+	}
+}
+
+// Returns the interface type declaration of the operand of the type switch
+// header, e.g. "x := v.(type)", nil if the operand is not of an interface type.
+func (c *auto_complete_context) deduce_type_switch_interface(header string) *decl {
+	operand := strings.TrimSuffix(header, ".(type)")
+	if i := strings.Index(operand, ":="); i != -1 {
+		operand = operand[i+len(":="):]
+	}
+	expr, err := parser.ParseExpr(operand)
+	if err != nil {
+		return nil
+	}
+	t, s, is_type := infer_type(expr, c.current.scope, -1)
+	if t == nil || is_type {
+		return nil
+	}
+	d := type_to_decl(t, s)
+	if d == nil {
+		return nil
+	}
+	d = advance_to_struct_or_interface(d)
+	if d == nil {
+		return nil
+	}
+	if _, ok := d.typ.(*ast.InterfaceType); !ok {
+		return nil
+	}
+	return d
+}
+
+// returns the name of the identifier, an empty string for other expressions
+func ident_name(e ast.Expr) string {
+	if ident, ok := e.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// Starting from the token right before the operand the cursor is at, figure
+// out the type expected at the cursor location. Examples (# - the cursor):
+//   f(a, #)           // the type of the second parameter of f
+//   x = #             // the type of x
+//   return a, #       // the type of the second result of the enclosing func
+//   T{A: 1, B: #}     // the type of the field B
+// Returns the type expression and the scope where it makes sense.
+func (c *auto_complete_context) deduce_expected_type(iter token_iterator) (ast.Expr, *scope) {
+	commas := 0
+	key := "" // struct literal field name, if the cursor is at "Key: #"
+	for {
+		switch tok := iter.token().tok; tok {
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if !iter.skip_to_balanced_pair() {
+				return nil, nil
+			}
+		case token.COMMA:
+			commas++
+		case token.COLON:
+			if key == "" {
+				if commas != 0 || !iter.go_back() || iter.token().tok != token.IDENT {
+					return nil, nil
+				}
+				key = iter.token().lit
+			}
+		case token.LPAREN:
+			if key != "" {
+				return nil, nil
+			}
+			return c.deduce_call_arg_type(iter, commas)
+		case token.LBRACE:
+			if key == "" {
+				return nil, nil
+			}
+			return c.deduce_field_type(iter, key)
+		case token.ASSIGN, token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN,
+			token.QUO_ASSIGN, token.REM_ASSIGN, token.AND_ASSIGN, token.OR_ASSIGN,
+			token.XOR_ASSIGN, token.AND_NOT_ASSIGN:
+			if key != "" {
+				return nil, nil
+			}
+			return c.deduce_assign_type(iter, commas)
+		case token.RETURN:
+			if key != "" || c.current.functype == nil {
+				return nil, nil
+			}
+			t := func_return_type(c.current.functype, commas)
+			if t == nil {
+				return nil, nil
+			}
+			return t, c.current.scope
+		case token.LBRACK, token.SEMICOLON, token.DEFINE:
+			return nil, nil
+		case token.FUNC, token.MAP, token.CHAN, token.STRUCT, token.INTERFACE:
+			// may be a part of an operand: f(func() {}, #)
+		default:
+			if tok.IsKeyword() {
+				return nil, nil
+			}
+		}
+		if !iter.go_back() {
+			return nil, nil
+		}
+	}
+}
+
+// the cursor is at the '(' of a call, 'index' is the argument position
+func (c *auto_complete_context) deduce_call_arg_type(iter token_iterator, index int) (ast.Expr, *scope) {
+	callee := iter.extract_go_expr()
+	if callee == "" {
+		return nil, nil
+	}
+	expr, err := parser.ParseExpr(callee)
+	if err != nil {
+		return nil, nil
+	}
+	t, s, is_type := infer_type(expr, c.current.scope, -1)
+	if t == nil {
+		return nil, nil
+	}
+	if is_type {
+		// type conversion: T(#)
+		if index != 0 {
+			return nil, nil
+		}
+		return t, s
+	}
+	t, s = advance_to_type(func_predicate, t, s)
+	ft, ok := t.(*ast.FuncType)
+	if !ok {
+		return nil, nil
+	}
+	if t = func_param_type(ft, index); t == nil {
+		return nil, nil
+	}
+	return t, s
+}
+
+// the cursor is at the '{' of a struct literal
+func (c *auto_complete_context) deduce_field_type(iter token_iterator, key string) (ast.Expr, *scope) {
+	d := c.deduce_struct_type_decl(&iter)
+	if d == nil {
+		return nil, nil
+	}
+	field := d.find_child(key)
+	if field == nil || field.class != decl_var {
+		return nil, nil
+	}
+	return field.typ, field.scope
+}
+
+// the cursor is at the '=' of an assignment, 'index' is the position of the
+// right hand side operand
+func (c *auto_complete_context) deduce_assign_type(iter token_iterator, index int) (ast.Expr, *scope) {
+	// collect left hand side operands, in reverse order
+	var lhs []string
+	for {
+		e := iter.extract_go_expr_before(token.PERIOD)
+		if e == "" {
+			break
+		}
+		lhs = append(lhs, e)
+		if iter.token().tok != token.COMMA {
+			break
+		}
+	}
+
+	var operand string
+	switch {
+	case len(lhs) == 1:
+		// either a single assignment or a typed var declaration:
+		// var a, b T = #
+		operand = lhs[0]
+	case index < len(lhs):
+		operand = lhs[len(lhs)-1-index]
+	default:
+		return nil, nil
+	}
+	expr, err := parser.ParseExpr(operand)
+	if err != nil {
+		return nil, nil
+	}
+	t, s, _ := infer_type(expr, c.current.scope, -1)
+	if t == nil {
+		return nil, nil
+	}
+	return t, s
+}
+
 // Entry point from autocompletion, the function looks at text before the cursor
 // and figures out the declaration the cursor is on. This declaration is
 // used in filtering the resulting set of autocompletion suggestions.
@@ -359,7 +976,13 @@ func (c *auto_complete_context) deduce_cursor_context(file []byte, cursor int) (
 		// we're '<whatever>.'
 		// figure out decl, Partial is ""
 		decl, expr := c.deduce_cursor_decl(&iter)
-		return cursor_context{decl: decl, expr: expr}, decl != nil
+		cc := cursor_context{decl: decl, expr: expr}
+		if decl != nil {
+			cc.ptr_methods = c.has_ptr_methods(expr)
+		}
+		cc.expected, cc.expected_scope = c.deduce_expected_type(iter)
+		c.deduce_switch_case(iter, ident_name(expr), &cc)
+		return cc, decl != nil
 	case token.IDENT, token.TYPE, token.CONST, token.VAR, token.FUNC, token.PACKAGE:
 		// we're '<whatever>.<ident>'
 		// parse <ident> as Partial and figure out decl
@@ -381,59 +1004,108 @@ func (c *auto_complete_context) deduce_cursor_context(file []byte, cursor int) (
 			partial = tok.literal()
 		}
 
-		iter.go_back()
+		if !iter.go_back() {
+			return cursor_context{partial: partial}, true
+		}
 		switch iter.token().tok {
 		case token.PERIOD:
 			decl, expr := c.deduce_cursor_decl(&iter)
-			return cursor_context{decl: decl, partial: partial, expr: expr}, decl != nil
+			cc := cursor_context{decl: decl, partial: partial, expr: expr}
+			if decl != nil {
+				cc.ptr_methods = c.has_ptr_methods(expr)
+			}
+			cc.expected, cc.expected_scope = c.deduce_expected_type(iter)
+			c.deduce_switch_case(iter, ident_name(expr), &cc)
+			return cc, decl != nil
 		case token.COMMA, token.LBRACE:
 			// This can happen for struct fields:
 			// &Struct{Hello: 1, Wor#} // (# - the cursor)
 			// Let's try to find the struct type
+			kwiter := iter
 			decl := c.deduce_struct_type_decl(&iter)
-			return cursor_context{
+			cc := cursor_context{
 				decl:         decl,
 				partial:      partial,
 				struct_field: decl != nil,
-			}, true
+			}
+			if decl == nil {
+				cc.expected, cc.expected_scope = c.deduce_expected_type(kwiter)
+				c.deduce_switch_case(kwiter, "", &cc)
+				cc.keyword = kwiter.keyword_position()
+			} else {
+				cc.struct_keys = kwiter.struct_literal_keys()
+			}
+			return cc, true
+		case token.BREAK, token.CONTINUE, token.GOTO:
+			return cursor_context{partial: partial, branch: iter.token().tok}, true
 		default:
-			return cursor_context{partial: partial}, true
+			cc := cursor_context{partial: partial}
+			cc.expected, cc.expected_scope = c.deduce_expected_type(iter)
+			c.deduce_switch_case(iter, "", &cc)
+			cc.keyword = iter.keyword_position()
+			return cc, true
+		}
+	case token.BREAK, token.CONTINUE, token.GOTO:
+		// "break #", a label is expected
+		if cursor > tok.off+len(tok.literal()) {
+			return cursor_context{branch: tok.tok}, true
+		}
+		fallthrough
+	default:
+		// a keyword typed in full, e.g. "if#", it still can be a prefix of
+		// something, like a snippet
+		if tok.tok.IsKeyword() && cursor-tok.off == len(tok.literal()) {
+			partial := tok.literal()
+			if !iter.go_back() {
+				return cursor_context{partial: partial}, true
+			}
+			return cursor_context{partial: partial, keyword: iter.keyword_position()}, true
 		}
 	case token.COMMA, token.LBRACE:
 		// Try to parse the current expression as a structure initialization.
+		kwiter := iter
 		decl := c.deduce_struct_type_decl(&iter)
-		return cursor_context{
+		cc := cursor_context{
 			decl:         decl,
 			partial:      "",
 			struct_field: decl != nil,
-		}, true
+		}
+		if decl == nil {
+			cc.expected, cc.expected_scope = c.deduce_expected_type(kwiter)
+			c.deduce_switch_case(kwiter, "", &cc)
+			cc.keyword = kwiter.keyword_position()
+		} else {
+			cc.struct_keys = kwiter.struct_literal_keys()
+		}
+		return cc, true
 	}
 
-	return cursor_context{}, true
+	var cc cursor_context
+	cc.expected, cc.expected_scope = c.deduce_expected_type(iter)
+	c.deduce_switch_case(iter, "", &cc)
+	cc.keyword = iter.keyword_position()
+	return cc, true
 }
 
 // Decl deduction failed, but we're on "<ident>.", this ident can be an
-// unexported package, let's try to match the ident against a set of known
-// packages and if it matches try to import it.
-// TODO: Right now I've made a static list of built-in packages, but in theory
-// we could scan all GOPATH packages as well. Now, don't forget that default
-// package name has nothing to do with package file name, that's why we need to
-// scan the packages. And many of them will have conflicts. Can we make a smart
-// prediction algorithm which will prefer certain packages over another ones?
-func resolveKnownPackageIdent(ident string, filename string, context *package_lookup_context) *package_file_cache {
-	importPath, ok := knownPackageIdents[ident]
-	if !ok {
-		return nil
-	}
+// unexported package, let's try to match the ident against the package index
+// and if it matches try to import it. The index knows all the packages of the
+// standard library, GOPATH and modules, the static list of known packages
+// below is used while the index is being built. Ambiguous names are resolved
+// in favor of packages imported by other files of the current package ('used'
+// import paths), then the standard library, then shorter paths.
+func resolveKnownPackageIdent(ident string, filename string, context *package_lookup_context, used map[string]bool) *package_file_cache {
+	for _, importPath := range g_daemon.pkgindex.lookup(ident, used) {
+		path, ok := abs_path_for_package(filename, importPath, context)
+		if !ok {
+			continue
+		}
 
-	path, ok := abs_path_for_package(filename, importPath, context)
-	if !ok {
-		return nil
+		p := new_package_file_cache(path, importPath)
+		p.update_cache()
+		return p
 	}
-
-	p := new_package_file_cache(path, importPath)
-	p.update_cache()
-	return p
+	return nil
 }
 
 var knownPackageIdents = map[string]string{
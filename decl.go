@@ -7,6 +7,7 @@ import (
 	"go/token"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -21,7 +22,10 @@ const (
 	decl_const
 	decl_func
 	decl_import
+	decl_keyword
+	decl_label
 	decl_package
+	decl_snippet
 	decl_type
 	decl_var
 
@@ -40,8 +44,14 @@ func (this decl_class) String() string {
 		return "func"
 	case decl_import:
 		return "import"
+	case decl_keyword:
+		return "keyword"
+	case decl_label:
+		return "label"
 	case decl_package:
 		return "package"
+	case decl_snippet:
+		return "snippet"
 	case decl_type:
 		return "type"
 	case decl_var:
@@ -65,6 +75,13 @@ const (
 	// decl of decl_type class is a type alias
 	decl_alias
 
+	// decl of decl_func class is a method with a pointer receiver, it's not
+	// in the method set of non-addressable values of the receiver type
+	decl_ptr_recv
+
+	// the doc comment of the decl has a "Deprecated: " paragraph
+	decl_deprecated
+
 	// for preventing infinite recursions and loops in type inference code
 	decl_visited
 )
@@ -101,6 +118,19 @@ type decl struct {
 	// scope where this Decl was declared in (not its visibilty scope!)
 	// Decl uses it for type inference
 	scope *scope
+
+	// location of the declaration, for declarations from the export data
+	// only the file and the line are known (and the column for newer
+	// formats), it's invalid if unknown
+	pos token.Position
+
+	// source range of the whole declaration, e.g. of a function with its
+	// body, known for declarations parsed from source only
+	beg, end token.Position
+
+	// doc comment, captured for declarations parsed from source only, see
+	// auto_complete_context.decl_doc
+	doc string
 }
 
 func ast_decl_type(d ast.Decl) ast.Expr {
@@ -129,6 +159,10 @@ func ast_decl_flags(d ast.Decl) decl_flags {
 				return decl_alias
 			}
 		}
+	case *ast.FuncDecl:
+		if method_has_ptr_recv(t) {
+			return decl_ptr_recv
+		}
 	}
 	return 0
 }
@@ -359,6 +393,14 @@ func method_of(d ast.Decl) string {
 	return ""
 }
 
+func method_has_ptr_recv(d *ast.FuncDecl) bool {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return false
+	}
+	_, ok := d.Recv.List[0].Type.(*ast.StarExpr)
+	return ok
+}
+
 func (other *decl) deep_copy() *decl {
 	d := new(decl)
 	d.name = other.name
@@ -376,6 +418,9 @@ func (other *decl) deep_copy() *decl {
 		copy(d.embedded, other.embedded)
 	}
 	d.scope = other.scope
+	d.pos = other.pos
+	d.beg, d.end = other.beg, other.end
+	d.doc = other.doc
 	return d
 }
 
@@ -387,6 +432,57 @@ func (d *decl) is_alias() bool {
 	return d.flags&decl_alias != 0
 }
 
+func (d *decl) is_ptr_method() bool {
+	return d.flags&decl_ptr_recv != 0
+}
+
+func (d *decl) is_deprecated() bool {
+	return d.flags&decl_deprecated != 0
+}
+
+// sets the doc comment of the decl parsed from source
+func (d *decl) set_doc(doc string) {
+	d.doc = doc
+	if is_deprecated_doc(doc) {
+		d.flags |= decl_deprecated
+	}
+}
+
+// sets locations, source ranges and doc comments of struct fields and
+// interface methods
+// declared right in the type of 'd', children are created from the AST which
+// has no positions
+func (d *decl) set_member_info(position func(token.Pos) token.Position) {
+	var fl *ast.FieldList
+	switch t := d.typ.(type) {
+	case *ast.StructType:
+		fl = t.Fields
+	case *ast.InterfaceType:
+		fl = t.Methods
+	}
+	if fl == nil {
+		return
+	}
+	for _, field := range fl.List {
+		if len(field.Names) == 0 {
+			// embedded field, its name is the name of the type
+			if c := d.find_child(get_type_path(field.Type).name); c != nil {
+				c.pos = position(field.Type.Pos())
+				c.beg, c.end = position(field.Pos()), position(field.End())
+				c.set_doc(field_doc(field))
+			}
+			continue
+		}
+		for _, name := range field.Names {
+			if c := d.find_child(name.Name); c != nil {
+				c.pos = position(name.Pos())
+				c.beg, c.end = position(field.Pos()), position(field.End())
+				c.set_doc(field_doc(field))
+			}
+		}
+	}
+}
+
 func (d *decl) is_visited() bool {
 	return d.flags&decl_visited != 0
 }
@@ -409,6 +505,9 @@ func (d *decl) expand_or_replace(other *decl) {
 		d.typ = other.typ
 		d.class = other.class
 		d.flags = other.flags
+		d.pos = other.pos
+		d.beg, d.end = other.beg, other.end
+		d.doc = other.doc
 	}
 
 	if other.children != nil {
@@ -525,6 +624,30 @@ func func_return_type(f *ast.FuncType, index int) ast.Expr {
 	return nil
 }
 
+// Returns the type of the parameter at the position 'index', arguments past
+// the last variadic parameter are its elements.
+func func_param_type(f *ast.FuncType, index int) ast.Expr {
+	if f.Params == nil {
+		return nil
+	}
+
+	i := 0
+	for _, field := range f.Params.List {
+		n := 1
+		if field.Names != nil {
+			n = len(field.Names)
+		}
+		if e, ok := field.Type.(*ast.Ellipsis); ok && index >= i {
+			return e.Elt
+		}
+		if i <= index && index < i+n {
+			return field.Type
+		}
+		i += n
+	}
+	return nil
+}
+
 type type_path struct {
 	pkg  string
 	name string
@@ -663,6 +786,14 @@ func struct_interface_predicate(v ast.Expr) bool {
 	return false
 }
 
+func composite_predicate(v ast.Expr) bool {
+	switch v.(type) {
+	case *ast.StructType, *ast.ArrayType, *ast.MapType:
+		return true
+	}
+	return false
+}
+
 func chan_predicate(v ast.Expr) bool {
 	_, ok := v.(*ast.ChanType)
 	return ok
@@ -1031,6 +1162,43 @@ func (d *decl) find_child(name string) *decl {
 	return nil
 }
 
+// Looks for the method 'name' of the type declaration, methods promoted from
+// embedded types included. 'ptr' is true if the method is in the method set
+// of the pointer type only: it has a pointer receiver and it's not promoted
+// through an embedded pointer.
+func (d *decl) find_method(name string) (m *decl, ptr bool) {
+	if d == nil {
+		return nil, false
+	}
+
+	if d.is_alias() {
+		dd := d.type_dealias()
+		if dd != nil {
+			return dd.find_method(name)
+		}
+	}
+
+	if d.is_visited() {
+		return nil, false
+	}
+	d.set_visited()
+	defer d.clear_visited()
+
+	if c := d.find_child(name); c != nil {
+		return c, c.is_ptr_method()
+	}
+	for _, e := range d.embedded {
+		m, ptr = type_to_decl(e, d.scope).find_method(name)
+		if m != nil {
+			if _, ok := e.(*ast.StarExpr); ok {
+				ptr = false
+			}
+			return m, ptr
+		}
+	}
+	return nil, false
+}
+
 func (d *decl) find_child_and_in_embedded(name string) *decl {
 	if d == nil {
 		return nil
@@ -1062,6 +1230,214 @@ func (d *decl) find_child_and_in_embedded(name string) *decl {
 	return c
 }
 
+//-------------------------------------------------------------------------
+// Type compatibility
+//
+// A rough approximation of Go's assignability rules, good enough to tell
+// whether a candidate makes sense in a place where a certain type is expected.
+//-------------------------------------------------------------------------
+
+// Reports whether a value of type 't' (which makes sense in the scope 'ts')
+// can be assigned to a variable of type 'expected' (in the scope 'es').
+func type_assignable(t ast.Expr, ts *scope, expected ast.Expr, es *scope, canonical_aliases map[string]string) bool {
+	if t == nil || expected == nil {
+		return false
+	}
+	if type_identical(t, ts, expected, es, canonical_aliases) {
+		return true
+	}
+
+	// a non-empty interface accepts all the types implementing it
+	switch expected.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+	default:
+		return false
+	}
+	ed := type_to_decl(expected, es)
+	if ed == nil || ed.class != decl_type {
+		return false
+	}
+	ed = advance_to_struct_or_interface(ed)
+	if ed == nil {
+		return false
+	}
+	if _, ok := ed.typ.(*ast.InterfaceType); !ok || len(ed.children) == 0 && len(ed.embedded) == 0 {
+		return false
+	}
+	return type_implements(t, ts, ed)
+}
+
+func type_identical(a ast.Expr, as *scope, b ast.Expr, bs *scope, canonical_aliases map[string]string) bool {
+	// variadic parameters are slices
+	if e, ok := a.(*ast.Ellipsis); ok {
+		a = &ast.ArrayType{Elt: e.Elt}
+	}
+	if e, ok := b.(*ast.Ellipsis); ok {
+		b = &ast.ArrayType{Elt: e.Elt}
+	}
+
+	switch at := a.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		switch b.(type) {
+		case *ast.Ident, *ast.SelectorExpr:
+		default:
+			return false
+		}
+		ad := type_to_decl(a, as)
+		bd := type_to_decl(b, bs)
+		if ad == nil || bd == nil || ad.class != decl_type || bd.class != decl_type {
+			return false
+		}
+		if ad.is_alias() {
+			if dd := ad.type_dealias(); dd != nil {
+				ad = dd
+			}
+		}
+		if bd.is_alias() {
+			if dd := bd.type_dealias(); dd != nil {
+				bd = dd
+			}
+		}
+		if ad == bd {
+			return true
+		}
+		if ad.scope == g_universe_scope || bd.scope == g_universe_scope || ad.name != bd.name {
+			return false
+		}
+
+		// the same type of an imported package may be represented by
+		// different declarations, depending on the package it was
+		// referred from, in that case compare qualified names
+		var abuf, bbuf bytes.Buffer
+		pretty_print_type_expr(&abuf, a, canonical_aliases)
+		pretty_print_type_expr(&bbuf, b, canonical_aliases)
+		return abuf.String() == bbuf.String()
+	case *ast.StarExpr:
+		bt, ok := b.(*ast.StarExpr)
+		return ok && type_identical(at.X, as, bt.X, bs, canonical_aliases)
+	case *ast.ArrayType:
+		bt, ok := b.(*ast.ArrayType)
+		if !ok || (at.Len == nil) != (bt.Len == nil) || get_array_len(at.Len) != get_array_len(bt.Len) {
+			return false
+		}
+		return type_identical(at.Elt, as, bt.Elt, bs, canonical_aliases)
+	case *ast.MapType:
+		bt, ok := b.(*ast.MapType)
+		return ok && type_identical(at.Key, as, bt.Key, bs, canonical_aliases) &&
+			type_identical(at.Value, as, bt.Value, bs, canonical_aliases)
+	case *ast.ChanType:
+		bt, ok := b.(*ast.ChanType)
+		return ok && type_identical(at.Value, as, bt.Value, bs, canonical_aliases)
+	case *ast.FuncType:
+		bt, ok := b.(*ast.FuncType)
+		if !ok {
+			return false
+		}
+		var abuf, bbuf bytes.Buffer
+		pretty_print_type_expr(&abuf, at, canonical_aliases)
+		pretty_print_type_expr(&bbuf, bt, canonical_aliases)
+		return abuf.String() == bbuf.String()
+	}
+	return false
+}
+
+// Reports whether the type 't' has all the methods of the interface
+// declaration 'iface'. Methods with pointer receivers count only if 't' is a
+// pointer type. Method signatures are not compared.
+func type_implements(t ast.Expr, ts *scope, iface *decl) bool {
+	d := type_to_decl(t, ts)
+	if d == nil || d.class != decl_type {
+		return false
+	}
+	if d == iface {
+		return true
+	}
+	ok, ptr := decl_implements(d, iface)
+	if _, star := t.(*ast.StarExpr); star {
+		return ok
+	}
+	return ok && !ptr
+}
+
+// Reports whether the type declaration 'd' or a pointer to it has all the
+// methods of the interface declaration 'iface', 'ptr' is true if only the
+// pointer type has them.
+func decl_implements(d, iface *decl) (ok bool, ptr bool) {
+	for _, name := range interface_method_names(iface) {
+		m, p := d.find_method(name)
+		if m == nil || m.class != decl_func {
+			return false, false
+		}
+		ptr = ptr || p
+	}
+	return true, ptr
+}
+
+// Returns names of all the methods of the interface declaration, including
+// methods of embedded interfaces.
+func interface_method_names(iface *decl) []string {
+	methods := interface_methods(iface)
+	names := make([]string, len(methods))
+	for i, m := range methods {
+		names[i] = m.name
+	}
+	return names
+}
+
+// Returns all the methods of the interface declaration in the order of their
+// declaration, methods of embedded interfaces are included.
+func interface_methods(iface *decl) []*decl {
+	if iface.is_visited() {
+		return nil
+	}
+	iface.set_visited()
+	defer iface.clear_visited()
+
+	var methods []*decl
+	seen := make(map[string]bool)
+	add := func(m *decl) {
+		if m != nil && !seen[m.name] {
+			seen[m.name] = true
+			methods = append(methods, m)
+		}
+	}
+	if it, ok := iface.typ.(*ast.InterfaceType); ok && it.Methods != nil {
+		for _, field := range it.Methods.List {
+			for _, name := range field.Names {
+				add(iface.children[name.Name])
+			}
+			if len(field.Names) != 0 {
+				continue
+			}
+
+			// embedded interface
+			ed := type_to_decl(field.Type, iface.scope)
+			if ed == nil {
+				continue
+			}
+			if ed = advance_to_struct_or_interface(ed); ed != nil {
+				for _, m := range interface_methods(ed) {
+					add(m)
+				}
+			}
+		}
+	}
+
+	// methods which are not a part of the type expression, e.g. the ones of
+	// the built-in "error" interface
+	rest := make([]string, 0, len(iface.children))
+	for name := range iface.children {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		add(iface.children[name])
+	}
+	return methods
+}
+
 // Special type inference for range statements.
 // [int], [int] := range [string]
 // [int], [value] := range [slice or array]
@@ -1308,11 +1684,32 @@ func ast_decl_values(d ast.Decl) []ast.Expr {
 	return nil
 }
 
+// Returns the source range of the declaration 'd' split by ast_decl_split: the
+// range of its spec if it's a part of a group, otherwise the range of 'd'.
+func ast_decl_range(d ast.Decl) (token.Pos, token.Pos) {
+	if t, ok := d.(*ast.GenDecl); ok && t.Lparen.IsValid() && len(t.Specs) == 1 {
+		return t.Specs[0].Pos(), t.Specs[0].End()
+	}
+	return d.Pos(), d.End()
+}
+
 func ast_decl_split(d ast.Decl) []ast.Decl {
 	var decls []ast.Decl
 	if t, ok := d.(*ast.GenDecl); ok {
 		decls = make([]ast.Decl, len(t.Specs))
+		var typ ast.Expr // type of the previous constant spec
 		for i, s := range t.Specs {
+			if v, ok := s.(*ast.ValueSpec); ok && t.Tok == token.CONST {
+				// implicit repetition of the previous type:
+				// const ( A T = iota; B; C )
+				if v.Type == nil && len(v.Values) == 0 {
+					spec := *v
+					spec.Type = typ
+					s = &spec
+				} else {
+					typ = v.Type
+				}
+			}
 			decl := new(ast.GenDecl)
 			*decl = *t
 			decl.Specs = make([]ast.Spec, 1)
@@ -62,6 +62,7 @@ type decl_file_cache struct {
 	filescope *scope
 
 	fset    *token.FileSet
+	shebang int // length of the filtered out shebang line
 	context *package_lookup_context
 }
 
@@ -96,15 +97,15 @@ func (f *decl_file_cache) read_file() {
 	if f.error != nil {
 		return
 	}
-	data, _ = filter_out_shebang(data)
 
 	f.process_data(data)
 }
 
 func (f *decl_file_cache) process_data(data []byte) {
 	var file *ast.File
+	data, f.shebang = filter_out_shebang(data)
 	f.fset = token.NewFileSet()
-	file, f.error = parser.ParseFile(f.fset, "", data, 0)
+	file, f.error = parser.ParseFile(f.fset, "", data, parser.ParseComments)
 	f.filescope = new_scope(nil)
 	for _, d := range file.Decls {
 		anonymify_ast(d, 0, f.filescope)
@@ -112,11 +113,24 @@ func (f *decl_file_cache) process_data(data []byte) {
 	f.packages = collect_package_imports(f.name, file.Decls, f.context)
 	f.decls = make(map[string]*decl, len(file.Decls))
 	for _, decl := range file.Decls {
-		append_to_top_decls(f.decls, decl, f.filescope)
+		append_to_top_decls(f.decls, decl, f.filescope, f.position)
 	}
 }
 
-func append_to_top_decls(decls map[string]*decl, decl ast.Decl, scope *scope) {
+func (f *decl_file_cache) position(p token.Pos) token.Position {
+	pos := f.fset.Position(p)
+	pos.Filename = f.name
+	if f.shebang != 0 && pos.IsValid() {
+		pos.Offset += f.shebang
+		pos.Line++
+	}
+	return pos
+}
+
+// Adds top-level declarations of the 'decl' to 'decls', methods are added as
+// children of their receiver types. 'position' converts positions of names
+// to their locations in the file.
+func append_to_top_decls(decls map[string]*decl, decl ast.Decl, scope *scope, position func(token.Pos) token.Position) {
 	foreach_decl(decl, func(data *foreach_decl_struct) {
 		class := ast_decl_class(data.decl)
 		for i, name := range data.names {
@@ -126,6 +140,11 @@ func append_to_top_decls(decls map[string]*decl, decl ast.Decl, scope *scope) {
 			if d == nil {
 				return
 			}
+			d.pos = position(name.Pos())
+			beg, end := ast_decl_range(data.decl)
+			d.beg, d.end = position(beg), position(end)
+			d.set_doc(ast_decl_doc(data.decl))
+			d.set_member_info(position)
 
 			methodof := method_of(decl)
 			if methodof != "" {
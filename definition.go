@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//-------------------------------------------------------------------------
+// Go to definition
+//
+// Finds where the identifier under the cursor is declared, using the same
+// machinery as the autocompletion does for the partial identifier.
+//-------------------------------------------------------------------------
+
+// Returns the location of the declaration of the identifier at the 'cursor',
+// the cursor may be anywhere within the identifier or right after it. Only
+// the file and the line are known for declarations from the export data.
+func (c *auto_complete_context) definition(file []byte, filename string, cursor int) (token.Position, error) {
+	d, err := c.cursor_decl(file, filename, cursor)
+	if err != nil {
+		return token.Position{}, err
+	}
+	if !d.pos.IsValid() {
+		return token.Position{}, fmt.Errorf("location of %q is unknown", d.name)
+	}
+	return c.decl_position(d), nil
+}
+
+// Returns the declaration of the identifier or the selector at the 'cursor'.
+func (c *auto_complete_context) cursor_decl(file []byte, filename string, cursor int) (*decl, error) {
+	end := ident_end(file, cursor)
+	if end == -1 {
+		return nil, errors.New("no identifier at the cursor")
+	}
+	c.process_file(file, filename, end)
+
+	cc, ok := c.deduce_cursor_context(file, end)
+	if cc.partial == "" {
+		return nil, errors.New("no identifier at the cursor")
+	}
+	if !ok {
+		return nil, fmt.Errorf("%q not found", cc.partial)
+	}
+
+	d := c.context_decl(cc, cc.partial)
+	if d == nil {
+		return nil, fmt.Errorf("%q not found", cc.partial)
+	}
+	return d, nil
+}
+
+// Returns the declaration 'name' would refer to at the cursor: a member of
+// cc.decl if it's known or a declaration visible in the current scope.
+func (c *auto_complete_context) context_decl(cc cursor_context, name string) *decl {
+	switch {
+	case cc.decl == nil:
+		return c.current.scope.lookup(name)
+	case cc.decl.class == decl_package:
+		return cc.decl.find_child(name)
+	}
+	d := cc.decl.find_child_and_in_embedded(name)
+	if d == nil {
+		d = advance_to_struct_or_interface(cc.decl).find_child_and_in_embedded(name)
+	}
+	return d
+}
+
+// Returns the location of the declaration with the file name usable as is.
+func (c *auto_complete_context) decl_position(d *decl) token.Position {
+	pos := d.pos
+	// the compiler writes paths of the standard library packages this way
+	if strings.HasPrefix(pos.Filename, "$GOROOT") {
+		goroot := c.current.context.GOROOT
+		pos.Filename = filepath.Join(goroot, filepath.FromSlash(pos.Filename[len("$GOROOT"):]))
+	}
+	return pos
+}
+
+func is_ident_rune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// returns the offset of the end of the identifier which contains the 'cursor'
+// or ends right before it, -1 if there is no such identifier
+func ident_end(file []byte, cursor int) int {
+	if cursor < 0 || cursor > len(file) {
+		return -1
+	}
+	end := cursor
+	for end < len(file) {
+		r, n := utf8.DecodeRune(file[end:])
+		if !is_ident_rune(r) {
+			break
+		}
+		end += n
+	}
+	if end == cursor {
+		r, _ := utf8.DecodeLastRune(file[:cursor])
+		if cursor == 0 || !is_ident_rune(r) {
+			return -1
+		}
+	}
+	return end
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefinition(t *testing.T) {
+	a := `package p
+
+type Base struct{}
+
+func (b *Base) Close() error { return nil }
+
+type T struct {
+	Base
+	count int
+}
+
+func f(t *T, n int) int {
+	x := n
+	%s
+	return x
+}
+`
+	b := "package p\n\nvar limit = 10\n"
+	for _, test := range []struct {
+		code, want string
+	}{
+		{"_ = x#", "a.go:13:2"},
+		{"_ = n#", "a.go:12:14"},
+		{"_ = limit#", "b.go:3:5"},
+		{"_ = t.count#", "a.go:9:2"},
+		// promoted from the embedded field
+		{"t.Close#()", "a.go:5:16"},
+		{"t.Base#.Close()", "a.go:8:2"},
+		{"var _ Base#", "a.go:3:6"},
+	} {
+		f, cleanup := write_test_package(t, map[string]string{
+			"a.go": fmt.Sprintf(a, test.code),
+			"b.go": b,
+		})
+		pos, err := server_definition(f.data, f.filename, f.cursor, test_context())
+		cleanup()
+		if err != nil {
+			t.Errorf("%s: %v", test.code, err)
+			continue
+		}
+		if got := fmt.Sprintf("%s:%d:%d", filepath.Base(pos.Filename), pos.Line, pos.Column); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.code, got, test.want)
+		}
+	}
+}
+
+func TestDefinitionNotFound(t *testing.T) {
+	f, cleanup := write_test_package(t, map[string]string{
+		"a.go": "package p\n\nfunc f() {\n\tundefined#()\n}\n",
+	})
+	defer cleanup()
+	if _, err := server_definition(f.data, f.filename, f.cursor, test_context()); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("got %v, want an error", err)
+	}
+}
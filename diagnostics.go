@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+//-------------------------------------------------------------------------
+// Diagnostics
+//
+// Reports problems of the current file the autocompletion notices anyway,
+// without running the compiler or any other tool.
+//-------------------------------------------------------------------------
+
+type diagnostic struct {
+	Pos token.Position
+	Msg string
+
+	// import path of the package an undefined identifier probably refers to
+	// and the edit which adds the import, empty if there is no fix
+	Import string
+	Edits  []text_edit
+}
+
+// Adds the syntax errors 'err' of the parse of the file without the ripped
+// off declaration if 'outer' is true, otherwise of the declaration. Errors at
+// the cursor are skipped, the code there is being typed and the semicolon
+// inserted there causes errors of its own. So are the errors at the end of the
+// declaration unless it's the end of the file: the parser runs into them when
+// it recovers from another error, the file goes on after the declaration.
+func (f *auto_complete_file) add_syntax_errors(outer bool, err error) {
+	const fixlen = len("package p;")
+	el, ok := err.(scanner.ErrorList)
+	if !ok {
+		return
+	}
+	for _, e := range el {
+		pos := f.buffer_position(outer, e.Pos.Offset)
+		if !pos.IsValid() || pos.Offset == f.semi || f.has_error(pos, e.Msg) {
+			continue
+		}
+		at_end := e.Pos.Offset >= fixlen+f.rip_len || strings.HasSuffix(e.Msg, "found 'EOF'")
+		if !outer && at_end && pos.Offset < len(f.buffer) {
+			continue
+		}
+		f.errors = append(f.errors, diagnostic{Pos: pos, Msg: e.Msg})
+	}
+}
+
+// both parses may run into the same error
+func (f *auto_complete_file) has_error(pos token.Position, msg string) bool {
+	for _, d := range f.errors {
+		if d.Pos.Offset == pos.Offset && d.Msg == msg {
+			return true
+		}
+	}
+	return false
+}
+
+// The semicolon inserted at the cursor may break code which is complete, e.g.
+// "g(a#, b)", then the parse of the declaration runs into errors caused by the
+// semicolon up to its end and the real ones get lost. Adds the errors of the
+// parse of the declaration without the semicolon, unless that parse fails at
+// the cursor or at the token after it: the code there is being typed.
+func (f *auto_complete_file) add_decl_errors() {
+	const fixlen = len("package p;")
+	if f.rip_len == 0 || f.semi == -1 {
+		return
+	}
+	block := f.buffer[f.rip_beg : f.rip_beg+f.rip_len-1]
+	_, err := parse_decl_list(token.NewFileSet(), block)
+	el, ok := err.(scanner.ErrorList)
+	if !ok || len(el) == 0 {
+		return
+	}
+	el.Sort()
+	first := f.rip_beg + el[0].Pos.Offset - fixlen
+	if first >= f.semi && len(bytes.TrimSpace(f.buffer[f.semi:first])) == 0 {
+		return
+	}
+
+	// the offsets of the parse are in the buffer as is
+	semi, rip_len := f.semi, f.rip_len
+	f.semi, f.rip_len = -1, rip_len-1
+	f.add_syntax_errors(false, el)
+	f.semi, f.rip_len = semi, rip_len
+}
+
+// Returns the problems of the file sorted by their locations, the 'cursor'
+// is the location of the cursor in the editor.
+func (c *auto_complete_context) diagnostics(file []byte, filename string, cursor int) []diagnostic {
+	c.process_file(file, filename, cursor)
+
+	// the semicolon inserted at the cursor may cause errors of its own, so
+	// the errors are reported only if the file doesn't parse without it
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, file, 0)
+	var diags []diagnostic
+	if err != nil {
+		c.current.add_decl_errors()
+		diags = append(diags, c.current.errors...)
+	} else {
+		// the check needs the complete AST
+		diags = c.check_file(file, f, fset)
+	}
+	sort.Sort(diagnostics_by_offset(diags))
+	return diags
+}
+
+// built-ins of the newer versions of Go the universe scope doesn't have
+var g_newer_builtins = map[string]bool{
+	"any":        true,
+	"comparable": true,
+	"clear":      true,
+	"min":        true,
+	"max":        true,
+}
+
+// an import of the file being checked
+type checked_import struct {
+	spec *ast.ImportSpec
+	path string
+	name string // empty if unknown: the package isn't loaded and has no alias
+	pkg  *decl  // nil if the package isn't loaded
+	used bool
+}
+
+// Reports identifiers declared nowhere, members of imported packages which
+// don't exist and imports which are not used. The identifiers go/parser
+// couldn't resolve within the file 'f' are looked up in the package scope
+// and in the imports, so the file must be processed already. Names of the
+// packages which aren't loaded are unknown, the check stays silent about
+// anything they might be responsible for.
+func (c *auto_complete_context) check_file(file []byte, f *ast.File, fset *token.FileSet) []diagnostic {
+	filename := c.current.name
+	var imports []*checked_import
+	names := make(map[string]*checked_import)
+	unknown_name, unknown_dot := false, false
+	for _, spec := range f.Imports {
+		path, alias := path_and_alias(spec)
+		imp := &checked_import{spec: spec, path: path, name: alias}
+		if abspath, ok := abs_path_for_package(filename, path, c.current.context); ok {
+			if p, ok := c.pcache[abspath]; ok && p.main != nil {
+				imp.pkg = p.main
+				if imp.name == "" {
+					imp.name = p.defalias
+				}
+			}
+		}
+		if imp.name == "" && is_std_import_path(path) {
+			imp.name = std_package_name(path)
+		}
+		switch imp.name {
+		case "_":
+			continue
+		case ".":
+			unknown_dot = unknown_dot || imp.pkg == nil
+			continue
+		case "":
+			unknown_name = true
+		default:
+			names[imp.name] = imp
+		}
+		imports = append(imports, imp)
+	}
+
+	keys := make(map[*ast.Ident]bool)
+	selectors := make(map[*ast.Ident]*ast.Ident)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.CompositeLit:
+			// keys may be struct fields, older versions of go/parser
+			// leave them unresolved
+			for _, e := range t.Elts {
+				if kv, ok := e.(*ast.KeyValueExpr); ok {
+					if id, ok := kv.Key.(*ast.Ident); ok {
+						keys[id] = true
+					}
+				}
+			}
+		case *ast.SelectorExpr:
+			if id, ok := t.X.(*ast.Ident); ok {
+				selectors[id] = t.Sel
+			}
+		}
+		return true
+	})
+
+	var diags []diagnostic
+	used := c.used_import_paths()
+	for _, id := range f.Unresolved {
+		sel := selectors[id]
+		if imp := names[id.Name]; imp != nil {
+			imp.used = true
+			if sel != nil && imp.pkg != nil && (!ast.IsExported(sel.Name) || imp.pkg.find_child(sel.Name) == nil) {
+				diags = append(diags, diagnostic{
+					Pos: fset.Position(sel.Pos()),
+					Msg: fmt.Sprintf("undefined: %s.%s", id.Name, sel.Name),
+				})
+			}
+			continue
+		}
+		if keys[id] || unknown_dot || c.pkg.lookup(id.Name) != nil || g_newer_builtins[id.Name] {
+			continue
+		}
+		if sel != nil && unknown_name {
+			// might be the package with the unknown name
+			continue
+		}
+
+		d := diagnostic{
+			Pos: fset.Position(id.Pos()),
+			Msg: "undefined: " + id.Name,
+		}
+		if sel != nil {
+			// probably a package which isn't imported
+			if paths := g_daemon.pkgindex.lookup(id.Name, used); len(paths) != 0 {
+				if edit, ok := import_edit(file, paths[0]); ok {
+					d.Import, d.Edits = paths[0], []text_edit{edit}
+				}
+			}
+		}
+		diags = append(diags, d)
+	}
+
+	for _, imp := range imports {
+		if imp.used || imp.name == "" || imp.path == "C" {
+			continue
+		}
+		msg := fmt.Sprintf("%q imported and not used", imp.path)
+		if imp.spec.Name != nil {
+			msg = fmt.Sprintf("%q imported as %s and not used", imp.path, imp.name)
+		}
+		diags = append(diags, diagnostic{Pos: fset.Position(imp.spec.Pos()), Msg: msg})
+	}
+	return diags
+}
+
+// Names of the standard library packages match the last elements of their
+// paths, except for the major version suffixes: "math/rand/v2" is "rand".
+func std_package_name(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && is_major_version(name) {
+		name = elems[len(elems)-2]
+	}
+	return name
+}
+
+func is_major_version(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, r := range s[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+type diagnostics_by_offset []diagnostic
+
+func (d diagnostics_by_offset) Len() int           { return len(d) }
+func (d diagnostics_by_offset) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d diagnostics_by_offset) Less(i, j int) bool { return d[i].Pos.Offset < d[j].Pos.Offset }
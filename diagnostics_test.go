@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func test_diagnostics(t *testing.T, files map[string]string) string {
+	f, cleanup := write_test_package(t, files)
+	defer cleanup()
+	diags, err := server_diagnostics(f.data, f.filename, f.cursor, test_context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, d := range diags {
+		s := fmt.Sprintf("%d:%d: %s", d.Pos.Line, d.Pos.Column, d.Msg)
+		for _, e := range d.Edits {
+			s += fmt.Sprintf(" [%s: %d %q]", d.Import, e.Offset, e.Text)
+		}
+		out = append(out, s)
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestDiagnosticsSyntax(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+	}{
+		// the code at the cursor is being typed
+		{"package p\n\nfunc f() {\n\tvar x struct{ a int }\n\tg(x.#)\n}\n", ""},
+		{"package p\n\nfunc f() {\n\tg(x.#)\n}\n\nvar v = [}\n", "7:10: expected operand, found '}'"},
+		{"package p\n\nfunc f() {\n\tg(x.#)\n\ty := ]\n}\n\nfunc h() {}\n", "5:7: expected operand, found ']'"},
+		// the parse of the declaration with the cursor runs into its end,
+		// it's not the end of the file
+		{"package p\n\nfunc f() {\n\tfor {\n\t\tg(#\n\t}\n}\n\nfunc h() {}\n", ""},
+		{"package p\n\nfunc f() {\n\ts := []int{#\n}\n\nfunc h() {}\n", ""},
+		// the semicolon at the cursor breaks the call, the declaration
+		// without it is parsed too
+		{"package p\n\nfunc f(a, b int) {\n\tg(a#, b)\n\tx := ]\n}\n", "5:7: expected operand, found ']'"},
+		// the end of the file
+		{"package p\n\nfunc f() {\n\tfor {\n\t\tg(#\n\t}\n}\n\nfunc h() {\n\tx := \n}\n",
+			"11:1: expected operand, found '}'\n12:1: expected ';', found 'EOF'\n12:1: expected '}', found 'EOF'"},
+	} {
+		if got := test_diagnostics(t, map[string]string{"a.go": test.src}); got != test.want {
+			t.Errorf("%q:\ngot:\n%s\nwant:\n%s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestDiagnosticsCheck(t *testing.T) {
+	got := test_diagnostics(t, map[string]string{
+		"a.go": `package p
+
+import (
+	"fmt"
+	r "math/rand"
+	"math/rand/v2"
+)
+
+func f() int {
+	g()
+	n := rand.IntN(10)
+	return strconv.Itoa(n) + missing#
+}
+`,
+		"b.go": "package p\n\nfunc g() {}\n",
+	})
+	want := `4:2: "fmt" imported and not used
+5:2: "math/rand" imported as r and not used
+12:9: undefined: strconv [strconv: 57 "\n\t\"strconv\""]
+12:27: undefined: missing`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStdPackageName(t *testing.T) {
+	for path, want := range map[string]string{
+		"fmt":          "fmt",
+		"net/http":     "http",
+		"math/rand/v2": "rand",
+		"v2":           "v2",
+		"go/version":   "version",
+	} {
+		if got := std_package_name(path); got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -157,8 +158,27 @@ func (*json_formatter) write_candidates(candidates []candidate, num int) {
 		if i != 0 {
 			fmt.Printf(", ")
 		}
-		fmt.Printf(`{"class": "%s", "name": "%s", "type": "%s", "package": "%s"}`,
-			c.Class, c.Name, c.Type, c.Package)
+		fmt.Printf(`{"class": "%s", "name": "%s", "type": "%s", "package": "%s", "score": %d`,
+			c.Class, c.Name, c.Type, c.Package, c.Score)
+		if c.Snippet != "" {
+			snippet, _ := json.Marshal(c.Snippet)
+			fmt.Printf(`, "snippet": %s`, snippet)
+		}
+		if len(c.Edits) != 0 {
+			fmt.Printf(`, "edits": [`)
+			for j, e := range c.Edits {
+				if j != 0 {
+					fmt.Printf(", ")
+				}
+				text, _ := json.Marshal(e.Text)
+				fmt.Printf(`{"offset": %d, "length": %d, "text": %s}`, e.Offset, e.Length, text)
+			}
+			fmt.Printf("]")
+		}
+		if c.Deprecated {
+			fmt.Printf(`, "deprecated": true`)
+		}
+		fmt.Printf("}")
 	}
 	fmt.Print("]]")
 }
@@ -4,6 +4,7 @@ import "C"
 import (
 	"fmt"
 	"go/build"
+	"strings"
 	"sync"
 	"unsafe"
 )
@@ -28,6 +29,7 @@ func newDaemon() *daemon {
 
 	d.pkgcache = new_package_cache()
 	d.declcache = new_decl_cache(&d.context)
+	d.pkgindex = new_package_index()
 	d.autocomplete = new_auto_complete_context(d.pkgcache, d.declcache)
 	//g_config.read()
 
@@ -91,7 +93,17 @@ func serverAutoComplete(aData uintptr, dataLen int, aFilename uintptr, fileNameL
 	candidates, n := server_auto_complete(file, filename, cursor, context)
 	//buffer := bytes.NewBuffer(nil)
 	for _, c := range candidates {
-		writeData(writeProc, outObj, fmt.Sprintf("%s,,%s,,%s\000", c.Class, c.Name, c.Type))
+		// edits are written as "offset:length:text", separated by '\001'
+		edits := make([]string, len(c.Edits))
+		for i, e := range c.Edits {
+			edits[i] = fmt.Sprintf("%d:%d:%s", e.Offset, e.Length, e.Text)
+		}
+		deprecated := 0
+		if c.Deprecated {
+			deprecated = 1
+		}
+		writeData(writeProc, outObj, fmt.Sprintf("%s,,%s,,%s,,%s,,%d,,%s,,%d\000",
+			c.Class, c.Name, c.Type, c.Snippet, c.Score, strings.Join(edits, "\001"), deprecated))
 		//buffer.WriteString()
 	}
 
@@ -99,3 +111,231 @@ func serverAutoComplete(aData uintptr, dataLen int, aFilename uintptr, fileNameL
 
 	return n
 }
+
+//export serverImplementStubs
+func serverImplementStubs(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, aType uintptr, typeLen int, aIface uintptr, ifaceLen int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	stubs, err := server_implement_stubs(file, filename, copyStr(aType, typeLen), copyStr(aIface, ifaceLen), context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	writeData(writeProc, outObj, stubs)
+	return 0
+}
+
+//export serverDefinition
+func serverDefinition(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, cursor int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	pos, err := server_definition(file, filename, cursor, context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	// "file:line:column" or "file:line" if the column is unknown
+	writeData(writeProc, outObj, pos.String())
+	return 0
+}
+
+//export serverHover
+func serverHover(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, cursor int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	h, err := server_hover(file, filename, cursor, context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	// the type and the doc may contain line breaks and commas, the doc goes
+	// last so that the host splits the output on the first four ",," only
+	writeData(writeProc, outObj, fmt.Sprintf("%s,,%s,,%s,,%s,,%s",
+		h.Class, h.Name, h.Type, h.Package, h.Doc))
+	return 0
+}
+
+//export serverSignatureHelp
+func serverSignatureHelp(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, cursor int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	sig, err := server_signature_help(file, filename, cursor, context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	// parameters are separated by '\001', like the edits of the candidates
+	writeData(writeProc, outObj, fmt.Sprintf("%s,,%s,,%d,,%s",
+		sig.Name, sig.Type, sig.Active, strings.Join(sig.Params, "\001")))
+	return 0
+}
+
+//export serverResolveCandidate
+func serverResolveCandidate(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, cursor int, aName uintptr, nameLen int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	c, err := server_resolve_candidate(file, filename, cursor, copyStr(aName, nameLen), context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	// same format as serverHover, the doc goes last
+	writeData(writeProc, outObj, fmt.Sprintf("%s,,%s,,%s,,%s,,%s",
+		c.Class, c.Name, c.Type, c.Package, c.Doc))
+	return 0
+}
+
+//export serverReferences
+func serverReferences(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, cursor int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	refs, err := server_references(file, filename, cursor, context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	// "file:line:column" per reference, the same as serverDefinition
+	for _, pos := range refs {
+		writeData(writeProc, outObj, pos.String()+"\000")
+	}
+	return len(refs)
+}
+
+//export serverSymbols
+func serverSymbols(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	// the file is read from the disk if there is no data
+	var file []byte
+	if aData != 0 {
+		file = []byte(copyStr(aData, dataLen))
+	}
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	symbols, err := server_symbols(file, filename, context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	n := 0
+	var write func(s symbol, parent int)
+	write = func(s symbol, parent int) {
+		// ranges are written as "line:column-line:column", children refer
+		// to the index of their parent, -1 for the top-level ones
+		writeData(writeProc, outObj, fmt.Sprintf("%s,,%s,,%d:%d,,%d:%d-%d:%d,,%d\000",
+			s.Class, s.Name, s.Pos.Line, s.Pos.Column,
+			s.Beg.Line, s.Beg.Column, s.End.Line, s.End.Column, parent))
+		index := n
+		n++
+		for _, c := range s.Children {
+			write(c, index)
+		}
+	}
+	for _, s := range symbols {
+		write(s, -1)
+	}
+	return n
+}
+
+//export serverRename
+func serverRename(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, cursor int, aName uintptr, nameLen int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	files, err := server_rename(file, filename, cursor, copyStr(aName, nameLen), context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	// edits are written the same way as the edits of the candidates, all of
+	// them are known before anything is written, so the host can apply them
+	// at once
+	for _, f := range files {
+		edits := make([]string, len(f.Edits))
+		for i, e := range f.Edits {
+			edits[i] = fmt.Sprintf("%d:%d:%s", e.Offset, e.Length, e.Text)
+		}
+		writeData(writeProc, outObj, fmt.Sprintf("%s,,%s\000", f.Filename, strings.Join(edits, "\001")))
+	}
+	return len(files)
+}
+
+//export serverDiagnostics
+func serverDiagnostics(aData uintptr, dataLen int, aFilename uintptr, fileNameLen int, cursor int, outObj uintptr, writeProc uintptr) int {
+	if g_daemon == nil {
+		return -1
+	}
+
+	file := []byte(copyStr(aData, dataLen))
+	filename := copyStr(aFilename, fileNameLen)
+
+	context := pack_build_context(&build.Default)
+
+	diags, err := server_diagnostics(file, filename, cursor, context)
+	if err != nil {
+		writeData(writeProc, outObj, err.Error())
+		return -1
+	}
+	for _, d := range diags {
+		// the fix is written the same way as the edits of the candidates
+		edits := make([]string, len(d.Edits))
+		for i, e := range d.Edits {
+			edits[i] = fmt.Sprintf("%d:%d:%s", e.Offset, e.Length, e.Text)
+		}
+		writeData(writeProc, outObj, fmt.Sprintf("%d:%d,,%d,,%s,,%s,,%s\000",
+			d.Pos.Line, d.Pos.Column, d.Pos.Offset, d.Msg, d.Import, strings.Join(edits, "\001")))
+	}
+	return len(diags)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//-------------------------------------------------------------------------
+// Hover
+//
+// Describes the declaration of the identifier under the cursor, that's what
+// editors show in a tooltip.
+//-------------------------------------------------------------------------
+
+type hover struct {
+	Name  string
+	Class decl_class
+
+	// type of the declaration, types are printed with their fields and
+	// methods, e.g.: "struct { X, Y int }"
+	Type string
+
+	// import path of the package the declaration belongs to, for the current
+	// package outside of GOPATH it's derived from the module path, outside of
+	// modules it's the package name
+	Package string
+
+	// doc comment, empty if there is none or the source is not available
+	Doc string
+}
+
+// Returns the description of the declaration of the identifier or the
+// selector at the 'cursor'.
+func (c *auto_complete_context) hover(file []byte, filename string, cursor int) (hover, error) {
+	d, err := c.cursor_decl(file, filename, cursor)
+	if err != nil {
+		return hover{}, err
+	}
+
+	canonical_aliases := new_out_buffers(c).canonical_aliases
+	var buf bytes.Buffer
+	switch d.class {
+	case decl_type:
+		if d.is_alias() {
+			buf.WriteString("= ")
+		}
+		pretty_print_type_body(&buf, d.typ, d.flags&decl_foreign != 0, canonical_aliases)
+	case decl_var, decl_const:
+		if t, _ := d.infer_type(); t != nil {
+			pretty_print_type_expr(&buf, t, canonical_aliases)
+		}
+	default:
+		d.pretty_print_type(&buf, canonical_aliases)
+	}
+
+	return hover{
+		Name:    d.name,
+		Class:   d.class,
+		Type:    buf.String(),
+		Package: c.decl_import_path(d),
+		Doc:     c.decl_doc(d),
+	}, nil
+}
+
+// Returns the import path of the package the declaration 'd' belongs to, for
+// packages themselves it's their own import path. Outside of GOPATH the import
+// path of the current package is derived from the module path, outside of
+// modules the package name is returned instead.
+func (c *auto_complete_context) decl_import_path(d *decl) string {
+	if d.class == decl_package {
+		for _, imp := range c.current.packages {
+			if p, ok := c.pcache[imp.abspath]; ok && p.main == d {
+				return imp.path
+			}
+		}
+		return ""
+	}
+	if d.flags&decl_foreign != 0 {
+		return c.decl_package_import_path(d)
+	}
+	if p := c.current.context.CurrentPackagePath; p != "" && p != "." {
+		return p
+	}
+	dir := filepath.Dir(c.current.name)
+	if modroot, modpath := find_module_root(dir); modroot != "" {
+		rel, err := filepath.Rel(modroot, dir)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return path.Join(modpath, filepath.ToSlash(rel))
+		}
+	}
+	return c.current.package_name
+}
+
+// Like pretty_print_type_expr, but prints fields of structs and methods of
+// interfaces, unexported ones are skipped for foreign types.
+func pretty_print_type_body(out io.Writer, e ast.Expr, foreign bool, canonical_aliases map[string]string) {
+	var fields []*ast.Field
+	switch t := e.(type) {
+	case *ast.StructType:
+		fmt.Fprintf(out, "struct {")
+		if t.Fields != nil {
+			fields = t.Fields.List
+		}
+	case *ast.InterfaceType:
+		fmt.Fprintf(out, "interface {")
+		if t.Methods != nil {
+			fields = t.Methods.List
+		}
+	default:
+		pretty_print_type_expr(out, e, canonical_aliases)
+		return
+	}
+
+	n := 0
+	for _, field := range fields {
+		var names []string
+		for _, name := range field.Names {
+			if !foreign || ast.IsExported(name.Name) {
+				names = append(names, name.Name)
+			}
+		}
+		if len(field.Names) != 0 && len(names) == 0 {
+			continue
+		}
+		if n == 0 {
+			fmt.Fprintf(out, "\n")
+		}
+		n++
+		fmt.Fprintf(out, "\t")
+		if ft, ok := field.Type.(*ast.FuncType); ok && len(names) != 0 {
+			// interface method, print it without the "func" keyword
+			var buf bytes.Buffer
+			pretty_print_type_expr(&buf, ft, canonical_aliases)
+			fmt.Fprintf(out, "%s%s\n", names[0], bytes.TrimPrefix(buf.Bytes(), []byte("func")))
+			continue
+		}
+		for i, name := range names {
+			if i > 0 {
+				fmt.Fprintf(out, ", ")
+			}
+			fmt.Fprintf(out, "%s", name)
+		}
+		if len(names) != 0 {
+			fmt.Fprintf(out, " ")
+		}
+		pretty_print_type_expr(out, field.Type, canonical_aliases)
+		fmt.Fprintf(out, "\n")
+	}
+	fmt.Fprintf(out, "}")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+)
+
+func test_hover(t *testing.T, files map[string]string) hover {
+	f, cleanup := write_test_package(t, files)
+	defer cleanup()
+	h, err := server_hover(f.data, f.filename, f.cursor, test_context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHover(t *testing.T) {
+	src := `package p
+
+// Point is a point.
+type Point struct {
+	X, Y int
+	name string
+}
+
+// Dist returns the distance.
+func (p Point) Dist(q Point) int { return 0 }
+
+func f() {
+	var p Point
+	p.Dist#(p)
+}
+`
+	h := test_hover(t, map[string]string{"a.go": src})
+	if h.Name != "Dist" || h.Class != decl_func || h.Type != "func(q Point) int" || h.Doc != "Dist returns the distance." {
+		t.Errorf("got %+v", h)
+	}
+
+	h = test_hover(t, map[string]string{"a.go": src[:len(src)-len("\tp.Dist#(p)\n}\n")] + "\tp#.Dist(p)\n}\n"})
+	want := "struct {\n\tX, Y int\n\tname string\n}"
+	if h.Name != "p" || h.Class != decl_var || h.Type != "Point" {
+		t.Errorf("got %+v", h)
+	}
+
+	h = test_hover(t, map[string]string{"a.go": "package p\n\n// Point is a point.\ntype Point struct {\n\tX, Y int\n\tname string\n}\n\nvar v Point#\n"})
+	if h.Type != want || h.Doc != "Point is a point." {
+		t.Errorf("got %+v, want the type %q", h, want)
+	}
+}
+
+func TestHoverPackage(t *testing.T) {
+	src := "package p\n\nfunc f#() {}\n"
+	// outside of modules, the package name
+	if h := test_hover(t, map[string]string{"a.go": src}); h.Package != "p" {
+		t.Errorf("got the package %q, want p", h.Package)
+	}
+	h := test_hover(t, map[string]string{
+		"go.mod":   "module example.com/m\n",
+		"sub/a.go": src,
+	})
+	if h.Package != "example.com/m/sub" {
+		t.Errorf("got the package %q, want example.com/m/sub", h.Package)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//-------------------------------------------------------------------------
+// matcher
+//
+// Filters candidates by the text typed before the cursor and scores the
+// quality of the match, see the "matcher" config option.
+//-------------------------------------------------------------------------
+
+type matcher int
+
+const (
+	matcher_prefix           matcher = iota // case-sensitive prefix
+	matcher_case_insensitive                // case-insensitive prefix
+	matcher_fuzzy                           // subsequence, camel humps are preferred
+)
+
+var g_string_to_matcher = map[string]matcher{
+	"prefix":           matcher_prefix,
+	"case-insensitive": matcher_case_insensitive,
+	"fuzzy":            matcher_fuzzy,
+}
+
+// Returns the matcher chosen in the config, "ignore-case" turns the default
+// prefix matcher into a case-insensitive one.
+func config_matcher() matcher {
+	m := g_string_to_matcher[g_config.Matcher]
+	if m == matcher_prefix && g_config.IgnoreCase {
+		m = matcher_case_insensitive
+	}
+	return m
+}
+
+// Matches the name 's' against the typed text 'p', returns the score of the
+// match and whether it matches at all.
+func (m matcher) match(s, p string) (int, bool) {
+	if strings.HasPrefix(s, p) {
+		return match_score(s, p, score_prefix), true
+	}
+	if m == matcher_prefix {
+		return 0, false
+	}
+	if has_prefix(s, p, true) {
+		return match_score(s, p, score_prefix_ignorecase), true
+	}
+	if m == matcher_case_insensitive {
+		return 0, false
+	}
+	return fuzzy_match(s, p)
+}
+
+func match_score(s, p string, score int) int {
+	if strings.EqualFold(s, p) {
+		score += score_exact
+	}
+	return score
+}
+
+// Case-insensitive subsequence match, e.g. "rdall" matches "ReadAll" and
+// "nrw" matches "NewReadWriter". Characters matched at word boundaries (camel
+// humps, after '_', '.' or '/') and runs of consecutive characters score
+// higher, the result is within [0, score_fuzzy].
+func fuzzy_match(s, p string) (int, bool) {
+	if p == "" {
+		return 0, true
+	}
+	points, npattern := 0, 0
+	si, end := 0, -1 // end is the offset right after the last matched character
+	for _, pr := range p {
+		pr = unicode.ToLower(pr)
+		npattern++
+		found := false
+		for si < len(s) {
+			r, size := utf8.DecodeRuneInString(s[si:])
+			if unicode.ToLower(r) != pr {
+				si += size
+				continue
+			}
+			if is_word_boundary(s, si) {
+				points += 2
+			}
+			if end == si {
+				points++
+			}
+			si += size
+			end = si
+			found = true
+			break
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return score_fuzzy * points / (3 * npattern), true
+}
+
+// reports whether a word starts at the byte offset 'i' of the identifier or
+// the import path 's'
+func is_word_boundary(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, size := utf8.DecodeRuneInString(s[i:])
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+	switch {
+	case prev == '_' || prev == '.' || prev == '/' || prev == '-':
+		return true
+	case unicode.IsUpper(r) && !unicode.IsUpper(prev):
+		// camelCase
+		return true
+	case unicode.IsUpper(r) && unicode.IsUpper(prev):
+		// the last letter of an acronym starts the next word: HTTPServer
+		next, _ := utf8.DecodeRuneInString(s[i+size:])
+		return unicode.IsLower(next)
+	case unicode.IsDigit(r) && !unicode.IsDigit(prev):
+		return true
+	}
+	return false
+}
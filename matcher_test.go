@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	for _, test := range []struct {
+		m     matcher
+		s, p  string
+		match bool
+	}{
+		{matcher_prefix, "ReadAll", "Read", true},
+		{matcher_prefix, "ReadAll", "read", false},
+		{matcher_prefix, "ReadAll", "rdall", false},
+		{matcher_case_insensitive, "ReadAll", "read", true},
+		{matcher_case_insensitive, "ReadAll", "rdall", false},
+		{matcher_fuzzy, "ReadAll", "rdall", true},
+		{matcher_fuzzy, "NewReadWriter", "NRW", true},
+		{matcher_fuzzy, "NewReadWriter", "nrw", true},
+		{matcher_fuzzy, "ReadAll", "lla", false},
+		{matcher_fuzzy, "ReadAll", "", true},
+	} {
+		if _, ok := test.m.match(test.s, test.p); ok != test.match {
+			t.Errorf("matcher %d: %q against %q: got %v, want %v", test.m, test.p, test.s, ok, test.match)
+		}
+	}
+}
+
+func TestMatcherScores(t *testing.T) {
+	score := func(s, p string) int {
+		n, ok := matcher_fuzzy.match(s, p)
+		if !ok {
+			t.Fatalf("%q doesn't match %q", p, s)
+		}
+		return n
+	}
+	// prefix matches, then case-insensitive ones, then fuzzy ones
+	if a, b := score("ReadAll", "Read"), score("ReadAll", "read"); a <= b {
+		t.Errorf("prefix %d <= case-insensitive prefix %d", a, b)
+	}
+	if a, b := score("ReadAll", "read"), score("ReadAll", "rdall"); a <= b {
+		t.Errorf("case-insensitive prefix %d <= fuzzy %d", a, b)
+	}
+	if a, b := score("Read", "Read"), score("ReadAll", "Read"); a <= b {
+		t.Errorf("exact %d <= prefix %d", a, b)
+	}
+	// word boundaries score higher than letters within the words
+	if a, b := score("NewReadWriter", "nrw"), score("NoRowsWanted", "now"); a <= b {
+		t.Errorf("camel humps %d <= letters within words %d", a, b)
+	}
+	if a, b := score("HTTPServer", "hs"), score("HTTPServer", "ts"); a <= b {
+		t.Errorf("acronym boundary %d <= letter within the acronym %d", a, b)
+	}
+	if n := score("ReadAll", "ReadAll"); n > score_prefix+score_exact {
+		t.Errorf("exact match scores %d", n)
+	}
+}
+
+func TestConfigMatcher(t *testing.T) {
+	defer func(c config) { g_config = c }(g_config)
+
+	g_config.Matcher, g_config.IgnoreCase = "prefix", true
+	if m := config_matcher(); m != matcher_case_insensitive {
+		t.Errorf("ignore-case: got matcher %d", m)
+	}
+	g_config.Matcher = "fuzzy"
+	if m := config_matcher(); m != matcher_fuzzy {
+		t.Errorf("fuzzy: got matcher %d", m)
+	}
+}
+
+func TestConfigSetMatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	c := g_default_config
+	c.set_option("matcher", "fuzzy")
+	if c.Matcher != "fuzzy" {
+		t.Errorf("got %q, want fuzzy", c.Matcher)
+	}
+	if out := c.set_option("matcher", "fuzy"); out != "matcher \"fuzzy\"\n" || c.Matcher != "fuzzy" {
+		t.Errorf("an unknown value was accepted: %q", out)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/token"
 	"os"
 	"strings"
 )
 
 type package_parser interface {
-	parse_export(callback func(pkg string, decl ast.Decl))
+	// 'pos' is the source position of the declaration, if the format has it
+	parse_export(callback func(pkg string, decl ast.Decl, pos token.Position))
 }
 
 //-------------------------------------------------------------------------
@@ -28,6 +30,13 @@ type package_file_cache struct {
 	scope  *scope
 	main   *decl // package declaration
 	others map[string]*decl
+
+	// type name -> constants of that type, see typed_consts
+	consts map[string][]*decl
+
+	// nil until the scan for deprecated members starts, see
+	// mark_deprecated_imports
+	deprecated *deprecated_scan
 }
 
 func new_package_file_cache(absname, name string) *package_file_cache {
@@ -105,6 +114,8 @@ func (m *package_file_cache) process_package_data(data []byte) {
 
 	// main package
 	m.main = new_decl(m.name, decl_package, nil)
+	m.consts = nil
+	m.deprecated = nil
 	// create map for other packages
 	m.others = make(map[string]*decl)
 
@@ -135,17 +146,17 @@ func (m *package_file_cache) process_package_data(data []byte) {
 	}
 
 	prefix := "!" + m.name + "!"
-	pp.parse_export(func(pkg string, decl ast.Decl) {
+	pp.parse_export(func(pkg string, decl ast.Decl, pos token.Position) {
 		anonymify_ast(decl, decl_foreign, m.scope)
 		if pkg == "" || strings.HasPrefix(pkg, prefix) {
 			// main package
-			add_ast_decl_to_package(m.main, decl, m.scope)
+			add_ast_decl_to_package(m.main, decl, m.scope, pos)
 		} else {
 			// others
 			if _, ok := m.others[pkg]; !ok {
 				m.others[pkg] = new_decl(pkg, decl_package, nil)
 			}
-			add_ast_decl_to_package(m.others[pkg], decl, m.scope)
+			add_ast_decl_to_package(m.others[pkg], decl, m.scope, pos)
 		}
 	})
 
@@ -166,12 +177,46 @@ func (m *package_file_cache) process_package_data(data []byte) {
 	}
 }
 
+// Returns constants of the package declared with the type 'typename' of the
+// same package, e.g. time.Sunday, time.Monday, ... for "Weekday". The index is
+// built on the first use from the declarations made by the export data parser.
+func (m *package_file_cache) typed_consts(typename string) []*decl {
+	if m.main == nil {
+		return nil
+	}
+	if m.consts == nil {
+		m.consts = make(map[string][]*decl)
+		for _, d := range m.main.children {
+			if d.class != decl_const {
+				continue
+			}
+			tp := get_type_path(d.typ)
+			if tp.pkg == "" || package_decl_path(lookup_pkg(tp, d.scope)) != m.name {
+				continue
+			}
+			m.consts[tp.name] = append(m.consts[tp.name], d)
+		}
+	}
+	return m.consts[typename]
+}
+
+// Returns the path of the package declaration name, packages referred to by
+// the export data are called "!path!alias".
+func package_decl_path(name string) string {
+	if strings.HasPrefix(name, "!") {
+		if i := strings.LastIndex(name, "!"); i > 0 {
+			return name[1:i]
+		}
+	}
+	return name
+}
+
 func (m *package_file_cache) add_package_to_scope(alias, realname string) {
 	d := new_decl(realname, decl_package, nil)
 	m.scope.add_decl(alias, d)
 }
 
-func add_ast_decl_to_package(pkg *decl, decl ast.Decl, scope *scope) {
+func add_ast_decl_to_package(pkg *decl, decl ast.Decl, scope *scope, pos token.Position) {
 	foreach_decl(decl, func(data *foreach_decl_struct) {
 		class := ast_decl_class(data.decl)
 		for i, name := range data.names {
@@ -181,6 +226,7 @@ func add_ast_decl_to_package(pkg *decl, decl ast.Decl, scope *scope) {
 			if d == nil {
 				return
 			}
+			d.pos = pos
 
 			if !name.IsExported() && d.class != decl_type {
 				return
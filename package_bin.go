@@ -56,7 +56,7 @@ type gc_bin_parser struct {
 	pathList      []string   // in order of appearance
 	pkgList       []string   // in order of appearance
 	typList       []ast.Expr // in order of appearance
-	callback      func(pkg string, decl ast.Decl)
+	callback      func(pkg string, decl ast.Decl, pos token.Position)
 	pfc           *package_file_cache
 	trackAllTypes bool
 
@@ -79,7 +79,7 @@ func (p *gc_bin_parser) init(data []byte, pfc *package_file_cache) {
 	p.pfc = pfc
 }
 
-func (p *gc_bin_parser) parse_export(callback func(string, ast.Decl)) {
+func (p *gc_bin_parser) parse_export(callback func(string, ast.Decl, token.Position)) {
 	p.callback = callback
 
 	// read version info
@@ -200,7 +200,7 @@ func (p *gc_bin_parser) pkg() string {
 func (p *gc_bin_parser) obj(tag int) {
 	switch tag {
 	case constTag:
-		p.pos()
+		pos := p.pos()
 		pkg, name := p.qualifiedName()
 		typ := p.typ("")
 		p.skipValue() // ignore const value, gocode's not interested
@@ -213,23 +213,23 @@ func (p *gc_bin_parser) obj(tag int) {
 					Values: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "0"}},
 				},
 			},
-		})
+		}, pos)
 
 	case aliasTag:
 		// TODO(gri) verify type alias hookup is correct
-		p.pos()
+		pos := p.pos()
 		pkg, name := p.qualifiedName()
 		typ := p.typ("")
 		p.callback(pkg, &ast.GenDecl{
 			Tok:   token.TYPE,
 			Specs: []ast.Spec{typeAliasSpec(name, typ)},
-		})
+		}, pos)
 
 	case typeTag:
 		_ = p.typ("")
 
 	case varTag:
-		p.pos()
+		pos := p.pos()
 		pkg, name := p.qualifiedName()
 		typ := p.typ("")
 		p.callback(pkg, &ast.GenDecl{
@@ -240,17 +240,17 @@ func (p *gc_bin_parser) obj(tag int) {
 					Type:  typ,
 				},
 			},
-		})
+		}, pos)
 
 	case funcTag:
-		p.pos()
+		pos := p.pos()
 		pkg, name := p.qualifiedName()
 		params := p.paramList()
 		results := p.paramList()
 		p.callback(pkg, &ast.FuncDecl{
 			Name: ast.NewIdent(name),
 			Type: &ast.FuncType{Params: params, Results: results},
-		})
+		}, pos)
 
 	default:
 		panic(fmt.Sprintf("unexpected object tag %d", tag))
@@ -259,9 +259,10 @@ func (p *gc_bin_parser) obj(tag int) {
 
 const deltaNewFile = -64 // see cmd/compile/internal/gc/bexport.go
 
-func (p *gc_bin_parser) pos() {
+// returns the position of the object being read, the format has no columns
+func (p *gc_bin_parser) pos() token.Position {
 	if !p.posInfoFormat {
-		return
+		return token.Position{}
 	}
 
 	file := p.prevFile
@@ -287,8 +288,7 @@ func (p *gc_bin_parser) pos() {
 	}
 	p.prevFile = file
 	p.prevLine = line
-
-	// TODO(gri) register new position
+	return token.Position{Filename: file, Line: line}
 }
 
 func (p *gc_bin_parser) qualifiedName() (pkg string, name string) {
@@ -333,7 +333,7 @@ func (p *gc_bin_parser) typ(parent string) ast.Expr {
 	switch i {
 	case namedTag:
 		// read type object
-		p.pos()
+		pos := p.pos()
 		parent, name := p.qualifiedName()
 		tdecl := &ast.GenDecl{
 			Tok: token.TYPE,
@@ -352,7 +352,7 @@ func (p *gc_bin_parser) typ(parent string) ast.Expr {
 		t0 := p.typ(parent)
 		tdecl.Specs[0].(*ast.TypeSpec).Type = t0
 
-		p.callback(parent, tdecl)
+		p.callback(parent, tdecl, pos)
 
 		// interfaces have no methods
 		if _, ok := t0.(*ast.InterfaceType); ok {
@@ -362,7 +362,7 @@ func (p *gc_bin_parser) typ(parent string) ast.Expr {
 		// read associated methods
 		for i := p.int(); i > 0; i-- {
 			// TODO(gri) replace this with something closer to fieldName
-			p.pos()
+			pos := p.pos()
 			name := p.string()
 			if !exported(name) {
 				p.pkg()
@@ -378,7 +378,7 @@ func (p *gc_bin_parser) typ(parent string) ast.Expr {
 				Recv: recv,
 				Name: ast.NewIdent(name),
 				Type: &ast.FuncType{Params: params, Results: results},
-			})
+			}, pos)
 		}
 		return t
 	case arrayTag:
@@ -70,7 +70,7 @@ func (r *intReader) uint64() uint64 {
 type gc_ibin_parser struct {
 	data     []byte
 	version  int
-	callback func(pkg string, decl ast.Decl)
+	callback func(pkg string, decl ast.Decl, pos token.Position)
 	pfc      *package_file_cache
 
 	stringData  []byte
@@ -133,7 +133,7 @@ func (p *gc_ibin_parser) init(data []byte, pfc *package_file_cache) {
 	p.pkgCache = make(map[uint64]ibinPackage)
 }
 
-func (p *gc_ibin_parser) parse_export(callback func(string, ast.Decl)) {
+func (p *gc_ibin_parser) parse_export(callback func(string, ast.Decl, token.Position)) {
 	const currentVersion = 0
 	p.callback = callback
 
@@ -233,11 +233,13 @@ type importReader struct {
 	p          *gc_ibin_parser
 	declReader bytes.Reader
 	currPkg    ibinPackage
+	prevFile   string
+	prevLine   int64
 }
 
 func (r *importReader) obj(name string) *ibinType {
 	tag := r.byte()
-	r.pos()
+	pos := r.pos()
 
 	switch tag {
 	case 'A':
@@ -245,7 +247,7 @@ func (r *importReader) obj(name string) *ibinType {
 		r.p.callback(r.currPkg.fullName, &ast.GenDecl{
 			Tok:   token.TYPE,
 			Specs: []ast.Spec{typeAliasSpec(name, typ.typ)},
-		})
+		}, pos)
 		return typ
 	case 'C':
 		typ := r.value()
@@ -258,14 +260,14 @@ func (r *importReader) obj(name string) *ibinType {
 					Values: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "0"}},
 				},
 			},
-		})
+		}, pos)
 		return typ
 	case 'F':
 		sig := r.signature()
 		r.p.callback(r.currPkg.fullName, &ast.FuncDecl{
 			Name: ast.NewIdent(name),
 			Type: sig,
-		})
+		}, pos)
 		return &ibinType{typ: sig}
 	case 'T':
 		// Types can be recursive. We need to setup a stub
@@ -281,7 +283,7 @@ func (r *importReader) obj(name string) *ibinType {
 					Type: t.und.typ,
 				},
 			},
-		})
+		}, pos)
 
 		if _, ok := t.und.typ.(*ast.InterfaceType); ok { // interfaces cannot have methods
 			return t
@@ -289,7 +291,7 @@ func (r *importReader) obj(name string) *ibinType {
 
 		// read associated methods
 		for n := r.uint64(); n > 0; n-- {
-			r.pos()
+			mpos := r.pos()
 			mname := r.ident()
 			recv := &ast.FieldList{List: []*ast.Field{r.param()}}
 			msig := r.signature()
@@ -298,7 +300,7 @@ func (r *importReader) obj(name string) *ibinType {
 				Recv: recv,
 				Name: ast.NewIdent(mname),
 				Type: msig,
-			})
+			}, mpos)
 		}
 		return t
 
@@ -312,7 +314,7 @@ func (r *importReader) obj(name string) *ibinType {
 					Type:  typ.typ,
 				},
 			},
-		})
+		}, pos)
 		return typ
 	default:
 		panic(fmt.Sprintf("unexpected tag: %v", tag))
@@ -336,13 +338,21 @@ const (
 	interfaceType
 )
 
-// we don't care about that, let's just skip it
-func (r *importReader) pos() {
-	if r.int64() != deltaNewFile {
+// positions are delta encoded relative to the previous one read by the
+// reader, the format has no columns
+func (r *importReader) pos() token.Position {
+	if delta := r.int64(); delta != deltaNewFile {
+		r.prevLine += delta
 	} else if l := r.int64(); l == -1 {
+		r.prevLine += deltaNewFile
 	} else {
-		r.string()
+		r.prevFile = r.string()
+		r.prevLine = l
+	}
+	if r.prevFile == "" {
+		return token.Position{}
 	}
+	return token.Position{Filename: r.prevFile, Line: int(r.prevLine)}
 }
 
 func (r *importReader) value() *ibinType {
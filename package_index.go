@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+//-------------------------------------------------------------------------
+// package_index
+//
+// Maps package names to import paths of all the packages gocode can find:
+// the standard library, GOPATH, the module cache and the current module.
+// The index is built in background, until it's ready only the static list of
+// known standard library packages is used.
+//-------------------------------------------------------------------------
+
+// how often the index is rebuilt to pick up newly installed packages
+const package_index_ttl = 10 * time.Minute
+
+type package_index struct {
+	sync.Mutex
+	names    map[string][]string // package name -> import paths
+	std      map[string]bool     // import paths of the standard library
+	key      string              // roots the index was built from
+	built    time.Time
+	building bool
+}
+
+func new_package_index() *package_index {
+	return &package_index{}
+}
+
+// Starts a background rebuild of the index if it was built for a different
+// set of roots or if it's too old.
+func (idx *package_index) refresh(context *package_lookup_context, filename string) {
+	modroot, modpath := find_module_root(filepath.Dir(filename))
+	key := strings.Join([]string{context.GOROOT, context.GOPATH, modroot}, string(filepath.ListSeparator))
+
+	idx.Lock()
+	defer idx.Unlock()
+	if idx.building || (idx.key == key && time.Since(idx.built) < package_index_ttl) {
+		return
+	}
+	idx.building = true
+
+	goroot := context.GOROOT
+	gopath := context.gopath()
+	go func() {
+		names, std := build_package_index(goroot, gopath, modroot, modpath)
+
+		idx.Lock()
+		idx.names, idx.std = names, std
+		idx.key, idx.built = key, time.Now()
+		idx.building = false
+		idx.Unlock()
+	}()
+}
+
+// Returns import paths of packages called 'name', the most probable ones go
+// first. 'used' is a set of import paths used by the current package.
+func (idx *package_index) lookup(name string, used map[string]bool) []string {
+	var paths []string
+	idx.Lock()
+	paths = append(paths, idx.names[name]...)
+	std := idx.std
+	idx.Unlock()
+
+	known, ok := knownPackageIdents[name]
+	if ok && !contains_string(paths, known) {
+		paths = append(paths, known)
+	}
+
+	ranked := import_path_slice{paths: paths, rank: make([]int, len(paths))}
+	for i, path := range paths {
+		switch {
+		case used[path]:
+			ranked.rank[i] = 0
+		case path == known:
+			// the static list resolves ambiguous standard library names
+			ranked.rank[i] = 1
+		case std[path]:
+			ranked.rank[i] = 2
+		default:
+			ranked.rank[i] = 3
+		}
+	}
+	sort.Sort(ranked)
+	return paths
+}
+
+// sorts import paths by rank, then by the number of path elements and length
+type import_path_slice struct {
+	paths []string
+	rank  []int
+}
+
+func (s import_path_slice) Len() int { return len(s.paths) }
+func (s import_path_slice) Swap(i, j int) {
+	s.paths[i], s.paths[j] = s.paths[j], s.paths[i]
+	s.rank[i], s.rank[j] = s.rank[j], s.rank[i]
+}
+func (s import_path_slice) Less(i, j int) bool {
+	if s.rank[i] != s.rank[j] {
+		return s.rank[i] < s.rank[j]
+	}
+	a, b := s.paths[i], s.paths[j]
+	if na, nb := strings.Count(a, "/"), strings.Count(b, "/"); na != nb {
+		return na < nb
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func contains_string(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func build_package_index(goroot string, gopath []string, modroot, modpath string) (map[string][]string, map[string]bool) {
+	start := time.Now()
+	names := make(map[string][]string)
+	std := make(map[string]bool)
+	add := func(name, path string) {
+		if !contains_string(names[name], path) {
+			names[name] = append(names[name], path)
+		}
+	}
+
+	if goroot != "" {
+		scan_package_dirs(filepath.Join(goroot, "src"), "", "cmd", func(name, path string) {
+			std[path] = true
+			add(name, path)
+		})
+	}
+	for _, p := range gopath {
+		scan_package_dirs(filepath.Join(p, "src"), "", "", add)
+	}
+	if modcache := module_cache_dir(gopath); modcache != "" {
+		scan_package_dirs(modcache, "", "cache", func(name, path string) {
+			if path, ok := module_cache_import_path(path); ok {
+				add(name, path)
+			}
+		})
+	}
+	if modroot != "" {
+		scan_package_dirs(modroot, modpath, "", add)
+	}
+
+	if *g_debug {
+		log.Printf("package index: %d names, built in %v", len(names), time.Since(start))
+	}
+	return names, std
+}
+
+// Walks the directory tree under 'root' and calls 'add' for every directory
+// with Go files, the import path of a directory is 'prefix' + its path
+// relative to 'root'. The 'skip' subdirectory of the root, test data, vendor
+// and "main" packages are skipped. Internal packages are importable only from
+// the current module, which is the only tree scanned with a 'prefix', thus
+// they are skipped everywhere else.
+func scan_package_dirs(root, prefix, skip string, add func(name, path string)) {
+	if !is_dir(root) {
+		return
+	}
+	filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return nil
+		}
+		base := fi.Name()
+		if path != root {
+			switch {
+			case base == "testdata", base == "vendor",
+				base == "internal" && prefix == "",
+				strings.HasPrefix(base, "."), strings.HasPrefix(base, "_"),
+				skip != "" && path == filepath.Join(root, skip):
+				return filepath.SkipDir
+			}
+		}
+		name := dir_package_name(path)
+		if name == "" || name == "main" || name == "documentation" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		ipath := filepath.ToSlash(rel)
+		if ipath == "." {
+			ipath = ""
+		}
+		if prefix != "" {
+			if ipath == "" {
+				ipath = prefix
+			} else {
+				ipath = prefix + "/" + ipath
+			}
+		}
+		if ipath != "" {
+			add(name, ipath)
+		}
+		return nil
+	})
+}
+
+// Returns the name of the package in the directory, reading only the package
+// clause of the first non-test Go file.
+func dir_package_name(dir string) string {
+	fis, err := readdir_lstat(dir)
+	if err != nil {
+		return ""
+	}
+	fset := token.NewFileSet()
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err != nil || f.Name == nil {
+			continue
+		}
+		return f.Name.Name
+	}
+	return ""
+}
+
+func module_cache_dir(gopath []string) string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	if len(gopath) == 0 {
+		return ""
+	}
+	return filepath.Join(gopath[0], "pkg", "mod")
+}
+
+// Converts a path relative to the module cache into an import path, e.g.
+// "github.com/!burnt!sushi/toml@v0.3.1/sub" -> "github.com/BurntSushi/toml/sub"
+func module_cache_import_path(path string) (string, bool) {
+	at := strings.Index(path, "@")
+	if at == -1 {
+		return "", false
+	}
+	module, rest := path[:at], path[at:]
+	if slash := strings.Index(rest, "/"); slash != -1 {
+		module += rest[slash:]
+	}
+
+	// upper case letters are escaped as '!' followed by the lower case letter
+	var buf []rune
+	upper := false
+	for _, r := range module {
+		if r == '!' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf = append(buf, r)
+	}
+	return string(buf), true
+}
+
+// Looks for go.mod in 'dir' and its parents, returns the module root
+// directory and the module path.
+func find_module_root(dir string) (string, string) {
+	for {
+		if modpath := read_module_path(filepath.Join(dir, "go.mod")); modpath != "" {
+			return dir, modpath
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+func read_module_path(gomod string) string {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		path := strings.TrimSpace(line[len("module"):])
+		if i := strings.Index(path, "//"); i != -1 {
+			path = strings.TrimSpace(path[:i])
+		}
+		return strings.Trim(path, "\"`")
+	}
+	return ""
+}
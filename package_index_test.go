@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPackageIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("GOMODCACHE", os.Getenv("GOMODCACHE"))
+	os.Unsetenv("GOMODCACHE")
+
+	// directory -> package name
+	for d, name := range map[string]string{
+		"goroot/src/fmt":                                     "fmt",
+		"goroot/src/encoding/json":                           "json",
+		"goroot/src/internal/cpu":                            "cpu",
+		"goroot/src/cmd/go":                                  "main",
+		"goroot/src/cmd/internal/obj":                        "obj",
+		"gopath/src/github.com/a/json":                       "json",
+		"gopath/src/github.com/a/json/testdata":              "json",
+		"gopath/src/example.com/long/path/json":              "json",
+		"gopath/src/example.com/tool":                        "main",
+		"gopath/pkg/mod/github.com/!burnt!sushi/toml@v0.3.1": "toml",
+		"mod/internal/util":                                  "util",
+		"mod/sub":                                            "sub",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(d))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		src := []byte("package " + name + "\n")
+		if err := ioutil.WriteFile(filepath.Join(path, "x.go"), src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, std := build_package_index(filepath.Join(dir, "goroot"), []string{filepath.Join(dir, "gopath")},
+		filepath.Join(dir, "mod"), "example.com/m")
+	for name, want := range map[string][]string{
+		"fmt":  {"fmt"},
+		"json": {"encoding/json", "example.com/long/path/json", "github.com/a/json"},
+		"toml": {"github.com/BurntSushi/toml"},
+		"util": {"example.com/m/internal/util"},
+		"sub":  {"example.com/m/sub"},
+		"cpu":  nil,
+		"obj":  nil,
+		"main": nil,
+	} {
+		got := names[name]
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+	if !std["encoding/json"] || std["github.com/a/json"] {
+		t.Errorf("got the standard library %v", std)
+	}
+
+	idx := new_package_index()
+	idx.names, idx.std = names, std
+	for _, test := range []struct {
+		name string
+		used map[string]bool
+		want []string
+	}{
+		// the standard library goes first, then shorter paths
+		{"json", nil, []string{"encoding/json", "github.com/a/json", "example.com/long/path/json"}},
+		// the imports of the current package go before everything else
+		{"json", map[string]bool{"example.com/long/path/json": true}, []string{"example.com/long/path/json", "encoding/json", "github.com/a/json"}},
+		// the static list is used when the index doesn't know the package
+		{"strconv", nil, []string{"strconv"}},
+		{"nonexistent", nil, nil},
+	} {
+		got := idx.lookup(test.name, test.used)
+		if !reflect.DeepEqual(got, test.want) && !(len(got) == 0 && len(test.want) == 0) {
+			t.Errorf("%s %v: got %v, want %v", test.name, test.used, got, test.want)
+		}
+	}
+}
@@ -668,7 +668,7 @@ func (p *gc_parser) parse_decl() (pkg string, decl ast.Decl) {
 
 // Export = PackageClause { Decl } "$$" .
 // PackageClause = "package" identifier [ "safe" ] "\n" .
-func (p *gc_parser) parse_export(callback func(string, ast.Decl)) {
+func (p *gc_parser) parse_export(callback func(string, ast.Decl, token.Position)) {
 	p.expect_keyword("package")
 	p.pfc.defalias = p.expect(scanner.Ident)
 	if p.tok != '\n' {
@@ -679,7 +679,8 @@ func (p *gc_parser) parse_export(callback func(string, ast.Decl)) {
 	for p.tok != '$' && p.tok != scanner.EOF {
 		pkg, decl := p.parse_decl()
 		if decl != nil {
-			callback(pkg, decl)
+			// textual format has no source positions
+			callback(pkg, decl, token.Position{})
 		}
 	}
 }
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"sort"
+)
+
+//-------------------------------------------------------------------------
+// References
+//
+// Finds references to the identifier under the cursor within the current
+// package. The declaration is found by the autocompletion machinery, the
+// references are found using the object resolution of go/parser: a local is
+// referenced by identifiers resolved to the same object within the function
+// and a package-level declaration is referenced by identifiers the parser
+// couldn't resolve within a file, because they refer to the package scope.
+// That takes care of shadowing.
+//-------------------------------------------------------------------------
+
+// a source file of the package, 'data' is nil if the file can't be read
+type package_file struct {
+	name string
+	data []byte
+}
+
+// kinds of the declarations references are found for
+type ref_kind int
+
+const (
+	ref_local        ref_kind = iota // local of a function
+	ref_package                      // package-level declaration
+	ref_imported                     // member of an imported package
+	ref_package_name                 // name of an imported package
+)
+
+// Returns locations of all the references to the identifier at the 'cursor',
+// including the declaration itself, sorted by files and offsets.
+func (c *auto_complete_context) references(file []byte, filename string, cursor int) ([]token.Position, error) {
+	refs, _, _, err := c.find_references(file, filename, cursor)
+	return refs, err
+}
+
+// Like references, also returns the kind of the declaration and the keys of
+// composite literals of unknown types which have the name of the declaration.
+// Such a key is either a field name or a reference.
+func (c *auto_complete_context) find_references(file []byte, filename string, cursor int) ([]token.Position, ref_kind, []token.Position, error) {
+	// the autocompletion machinery doesn't see locals at their declarations,
+	// e.g. parameters, so locals are handled by go/parser alone
+	fset := token.NewFileSet()
+	if f := parse_package_file(fset, package_file{filename, file}); f != nil {
+		id := ident_at_cursor(f, fset, cursor)
+		if id != nil && is_member_ident(f, id) {
+			return nil, 0, nil, fmt.Errorf("references to members of %q are not supported", id.Name)
+		}
+		if id != nil && id.Obj != nil && f.Scope.Lookup(id.Name) != id.Obj {
+			refs, keys := local_references(f, fset, id)
+			sort.Sort(positions(refs))
+			return refs, ref_local, keys, nil
+		}
+	}
+
+	d, err := c.cursor_decl(file, filename, cursor)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	var refs, keys []token.Position
+	var kind ref_kind
+	switch s := c.decl_scope(d); {
+	case d.class == decl_package:
+		refs, kind = c.package_name_references(d), ref_package_name
+	case s == g_universe_scope:
+		return nil, 0, nil, fmt.Errorf("%q is a built-in", d.name)
+	case s == c.pkg:
+		refs, keys, err = c.package_references(d)
+		kind = ref_package
+	case s != nil:
+		refs, keys, err = c.decl_local_references(d)
+		kind = ref_local
+	case d.flags&decl_foreign != 0:
+		refs, err = c.imported_references(d)
+		kind = ref_imported
+	default:
+		// struct fields and methods require types of the operands
+		return nil, 0, nil, fmt.Errorf("references to members of %q are not supported", d.name)
+	}
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	sort.Sort(positions(refs))
+	return refs, kind, keys, nil
+}
+
+// Reports whether 'id' declares a method or a struct field, or refers to an
+// interface method or a field within its type. go/parser resolves the latter
+// like locals.
+func is_member_ident(file *ast.File, id *ast.Ident) bool {
+	for _, decl := range file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv != nil && fd.Name == id {
+			return true
+		}
+	}
+	if id.Obj == nil {
+		return false
+	}
+	field, ok := id.Obj.Decl.(*ast.Field)
+	if !ok {
+		return false
+	}
+	member := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		var fl *ast.FieldList
+		switch t := n.(type) {
+		case *ast.StructType:
+			fl = t.Fields
+		case *ast.InterfaceType:
+			fl = t.Methods
+		}
+		if fl != nil {
+			for _, f := range fl.List {
+				if f == field {
+					member = true
+				}
+			}
+		}
+		return !member
+	})
+	return member
+}
+
+// sorts positions by files and offsets
+type positions []token.Position
+
+func (p positions) Len() int      { return len(p) }
+func (p positions) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p positions) Less(i, j int) bool {
+	if p[i].Filename != p[j].Filename {
+		return p[i].Filename < p[j].Filename
+	}
+	return p[i].Offset < p[j].Offset
+}
+
+// Returns the scope the declaration 'd' is visible in at the cursor, nil if
+// it's not in the scope chain, e.g. a struct field.
+func (c *auto_complete_context) decl_scope(d *decl) *scope {
+	for s := c.current.scope; s != nil; s = s.parent {
+		if s.entities[d.name] == d {
+			return s
+		}
+	}
+	return nil
+}
+
+// Returns the current file, as it's being edited, and the other files of the
+// package.
+func (c *auto_complete_context) package_files() []package_file {
+	files := []package_file{{c.current.name, c.current.buffer}}
+	for _, other := range c.others {
+		data, _ := ioutil.ReadFile(other.name)
+		files = append(files, package_file{other.name, data})
+	}
+	return files
+}
+
+func parse_package_file(fset *token.FileSet, f package_file) *ast.File {
+	if f.data == nil {
+		return nil
+	}
+	file, _ := parser.ParseFile(fset, f.name, f.data, 0)
+	return file
+}
+
+// returns the identifier which starts at the 'offset' of the 'file'
+func ident_at(file *ast.File, fset *token.FileSet, offset int) *ast.Ident {
+	var found *ast.Ident
+	ast.Inspect(file, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok && fset.Position(id.Pos()).Offset == offset {
+			found = id
+		}
+		return found == nil
+	})
+	return found
+}
+
+func ident_position(fset *token.FileSet, id *ast.Ident) token.Position {
+	return fset.Position(id.Pos())
+}
+
+// returns the identifier which contains the 'cursor' or ends right before it
+func ident_at_cursor(file *ast.File, fset *token.FileSet, cursor int) *ast.Ident {
+	var found *ast.Ident
+	ast.Inspect(file, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			off := fset.Position(id.Pos()).Offset
+			if off <= cursor && cursor <= off+len(id.Name) {
+				found = id
+			}
+		}
+		return found == nil
+	})
+	return found
+}
+
+func (c *auto_complete_context) decl_local_references(d *decl) ([]token.Position, []token.Position, error) {
+	if !d.pos.IsValid() || d.pos.Filename != c.current.name {
+		return nil, nil, fmt.Errorf("location of %q is unknown", d.name)
+	}
+	fset := token.NewFileSet()
+	file := parse_package_file(fset, package_file{c.current.name, c.current.buffer})
+	if file == nil {
+		return nil, nil, errors.New("can't parse the file")
+	}
+	id := ident_at(file, fset, d.pos.Offset)
+	if id == nil || id.Obj == nil {
+		return nil, nil, fmt.Errorf("declaration of %q not found", d.name)
+	}
+	refs, keys := local_references(file, fset, id)
+	return refs, keys, nil
+}
+
+// Locals are searched for within the top-level declaration they are in, the
+// identifiers must be resolved to the same object as the local 'id'. Also
+// returns the keys of literals of unknown types resolved to the object.
+func local_references(file *ast.File, fset *token.FileSet, id *ast.Ident) ([]token.Position, []token.Position) {
+	var refs, unknown []token.Position
+	keys := literal_keys(file, nil)
+	for _, decl := range file.Decls {
+		if decl.Pos() > id.Pos() || id.Pos() >= decl.End() {
+			continue
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			ref, ok := n.(*ast.Ident)
+			if !ok || ref.Obj != id.Obj {
+				return true
+			}
+			switch kind, ok := keys[ref]; {
+			case !ok || kind == key_value:
+				refs = append(refs, ident_position(fset, ref))
+			case kind == key_unknown:
+				unknown = append(unknown, ident_position(fset, ref))
+			}
+			return true
+		})
+	}
+	return refs, unknown
+}
+
+// kinds of the keys of composite literals
+type literal_key int
+
+const (
+	key_value   literal_key = iota // map key or array index, an expression
+	key_field                      // struct field name
+	key_unknown                    // the type of the literal is unknown
+)
+
+// Classifies the identifiers used as keys of composite literals in the 'file'
+// by the types of the literals. go/parser resolves the keys like any other
+// identifiers, though the keys of struct literals are field names. The type
+// names unresolved within the file are looked up in the package-level 'types'
+// of the other files, the elided types of nested literals are taken from the
+// enclosing ones.
+func literal_keys(file *ast.File, types map[string]*ast.Object) map[*ast.Ident]literal_key {
+	keys := make(map[*ast.Ident]literal_key)
+	var walk func(lit *ast.CompositeLit, typ ast.Expr)
+	inspect := func(n ast.Node) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.CompositeLit); ok {
+				walk(lit, lit.Type)
+				return false
+			}
+			return true
+		})
+	}
+	walk = func(lit *ast.CompositeLit, typ ast.Expr) {
+		if lit.Type != nil {
+			inspect(lit.Type)
+		}
+		typ = literal_type(typ, types)
+		for _, e := range lit.Elts {
+			key, value := ast.Expr(nil), e
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				key, value = kv.Key, kv.Value
+			}
+			var elem ast.Expr
+			switch t := typ.(type) {
+			case *ast.MapType:
+				elem = t.Value
+				if sub := elided_literal(key); sub != nil {
+					walk(sub, t.Key)
+					key = nil
+				}
+			case *ast.ArrayType:
+				elem = t.Elt
+			case *ast.StructType:
+				if id, ok := key.(*ast.Ident); ok {
+					elem = field_type(t, id.Name)
+				}
+			}
+			if id, ok := key.(*ast.Ident); ok {
+				switch typ.(type) {
+				case *ast.MapType, *ast.ArrayType:
+					keys[id] = key_value
+				case *ast.StructType:
+					keys[id] = key_field
+				default:
+					keys[id] = key_unknown
+				}
+			} else if key != nil {
+				inspect(key)
+			}
+			if sub := elided_literal(value); sub != nil {
+				walk(sub, elem)
+			} else {
+				inspect(value)
+			}
+		}
+	}
+	inspect(file)
+	return keys
+}
+
+// returns the literal with the elided type, e.g. {1, 2} or &{1, 2}, or nil
+func elided_literal(e ast.Expr) *ast.CompositeLit {
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		e = u.X
+	}
+	if lit, ok := e.(*ast.CompositeLit); ok && lit.Type == nil {
+		return lit
+	}
+	return nil
+}
+
+// Returns the type a composite literal of the type 'e' is checked against,
+// following the type names declared in the package. Returns nil if the type
+// is unknown.
+func literal_type(e ast.Expr, types map[string]*ast.Object) ast.Expr {
+	// the limit guards against invalid recursive declarations
+	for i := 0; i < 16; i++ {
+		switch t := e.(type) {
+		case *ast.ParenExpr:
+			e = t.X
+		case *ast.StarExpr:
+			// the elided type of &{...}
+			e = t.X
+		case *ast.Ident:
+			obj := t.Obj
+			if obj == nil {
+				obj = types[t.Name]
+			}
+			if obj == nil || obj.Kind != ast.Typ {
+				return nil
+			}
+			spec, ok := obj.Decl.(*ast.TypeSpec)
+			if !ok {
+				return nil
+			}
+			e = spec.Type
+		case *ast.MapType, *ast.ArrayType, *ast.StructType:
+			return e
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// returns the type of the field 'name' of the struct 't', nil if it's unknown
+func field_type(t *ast.StructType, name string) ast.Expr {
+	for _, f := range t.Fields.List {
+		for _, n := range f.Names {
+			if n.Name == name {
+				return f.Type
+			}
+		}
+	}
+	return nil
+}
+
+// Package-level declarations are referenced by the identifiers which are
+// resolved to their objects in the declaring file and by the unresolved
+// identifiers with the same name in all the files of the package. go/parser
+// doesn't report unresolved keys of composite literals, the ones of map and
+// array literals are references as well. Also returns the keys of literals
+// of unknown types which may refer to the declaration.
+func (c *auto_complete_context) package_references(d *decl) ([]token.Position, []token.Position, error) {
+	if !d.pos.IsValid() {
+		return nil, nil, fmt.Errorf("location of %q is unknown", d.name)
+	}
+	var refs, unknown []token.Position
+	fset := token.NewFileSet()
+	var files []*ast.File
+	types := make(map[string]*ast.Object)
+	for _, f := range c.package_files() {
+		if file := parse_package_file(fset, f); file != nil {
+			files = append(files, file)
+			for name, obj := range file.Scope.Objects {
+				if obj.Kind == ast.Typ {
+					types[name] = obj
+				}
+			}
+		}
+	}
+	for _, file := range files {
+		filename := fset.Position(file.Pos()).Filename
+		var obj *ast.Object
+		if filename == d.pos.Filename {
+			if id := ident_at(file, fset, d.pos.Offset); id != nil {
+				obj = id.Obj
+			}
+		}
+		unresolved := make(map[*ast.Ident]bool)
+		for _, id := range file.Unresolved {
+			if id.Name == d.name {
+				unresolved[id] = true
+			}
+		}
+		keys := literal_keys(file, types)
+		ast.Inspect(file, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || id.Name != d.name {
+				return true
+			}
+			kind, key := keys[id]
+			if !unresolved[id] && !(obj != nil && id.Obj == obj) && !(key && id.Obj == nil) {
+				return true
+			}
+			switch {
+			case !key || kind == key_value:
+				refs = append(refs, ident_position(fset, id))
+			case kind == key_unknown:
+				unknown = append(unknown, ident_position(fset, id))
+			}
+			return true
+		})
+	}
+	return refs, unknown, nil
+}
+
+// Members of imported packages are referenced by selectors with the alias of
+// the package in every file which imports it, unless the alias is shadowed.
+func (c *auto_complete_context) imported_references(d *decl) ([]token.Position, error) {
+	abspath := ""
+	for path, p := range c.pcache {
+		if p.main != nil && p.main.find_child(d.name) == d {
+			abspath = path
+			break
+		}
+	}
+	if abspath == "" {
+		// struct fields and methods require types of the operands
+		return nil, fmt.Errorf("references to members of %q are not supported", d.name)
+	}
+
+	imports := map[string][]package_import{c.current.name: c.current.packages}
+	for _, other := range c.others {
+		imports[other.name] = other.packages
+	}
+
+	var refs []token.Position
+	fset := token.NewFileSet()
+	for _, f := range c.package_files() {
+		aliases := make(map[string]bool)
+		for _, imp := range imports[f.name] {
+			if imp.abspath == abspath {
+				aliases[imp.alias] = true
+			}
+		}
+		if len(aliases) == 0 {
+			continue
+		}
+		file := parse_package_file(fset, f)
+		if file == nil {
+			continue
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != d.name {
+				return true
+			}
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil && aliases[x.Name] {
+				refs = append(refs, ident_position(fset, sel.Sel))
+			}
+			return true
+		})
+	}
+	return refs, nil
+}
+
+// Package names are visible in the file which imports them only.
+func (c *auto_complete_context) package_name_references(d *decl) []token.Position {
+	fset := token.NewFileSet()
+	file := parse_package_file(fset, package_file{c.current.name, c.current.buffer})
+	if file == nil {
+		return nil
+	}
+	var refs []token.Position
+	for _, id := range file.Unresolved {
+		if id.Name == d.name {
+			refs = append(refs, ident_position(fset, id))
+		}
+	}
+	for _, imp := range file.Imports {
+		if imp.Name != nil && imp.Name.Name == d.name {
+			refs = append(refs, ident_position(fset, imp.Name))
+		}
+	}
+	return refs
+}
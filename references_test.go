@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func test_references(t *testing.T, files map[string]string) ([]string, error) {
+	f, cleanup := write_test_package(t, files)
+	defer cleanup()
+	refs, err := server_references(f.data, f.filename, f.cursor, test_context())
+	var out []string
+	for _, r := range refs {
+		out = append(out, fmt.Sprintf("%s:%d:%d", r.Filename[strings.LastIndex(r.Filename, "/")+1:], r.Line, r.Column))
+	}
+	return out, err
+}
+
+func TestReferencesShadowed(t *testing.T) {
+	refs, err := test_references(t, map[string]string{
+		"a.go": `package p
+
+var count# int
+
+func f(n int) {
+	count += n
+	for count := 0; count < n; count++ {
+	}
+}
+`,
+		"b.go": `package p
+
+func g() { count++ }
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.go:3:5 a.go:6:2 b.go:3:12"
+	if got := strings.Join(refs, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestReferencesLocal(t *testing.T) {
+	refs, err := test_references(t, map[string]string{
+		"a.go": `package p
+
+func f(n# int) int {
+	for i := 0; i < n; i++ {
+		n := i
+		_ = n
+	}
+	return n
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.go:3:8 a.go:4:18 a.go:8:9"
+	if got := strings.Join(refs, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestReferencesMembers(t *testing.T) {
+	for _, src := range []string{
+		"package p\n\ntype T struct{ count# int }\n\nfunc f(t T) int { return t.count }\n",
+		"package p\n\ntype I interface{ Close#() }\n\nfunc f(i I) { i.Close() }\n",
+		"package p\n\ntype T int\n\nfunc (T) Close#() {}\n\nfunc Close() {}\n",
+		"package p\n\ntype T struct{ count int }\n\nfunc f(t T) int { return t.count# }\n",
+	} {
+		refs, err := test_references(t, map[string]string{"a.go": src})
+		if err == nil || !strings.Contains(err.Error(), "members") {
+			t.Errorf("%q: got %v, %v, want an error about members", src, refs, err)
+		}
+	}
+}
+
+func TestReferencesLiteralKeys(t *testing.T) {
+	refs, err := test_references(t, map[string]string{
+		"a.go": `package p
+
+type S struct{ count int }
+
+var count# = 1
+
+var s = S{count: count}
+
+var m = map[int]string{count: "a"}
+`,
+		"b.go": `package p
+
+var t = []S{{count: count}}
+
+var a = [...]int{count: 1}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the keys of the struct literals are field names
+	want := "a.go:5:5 a.go:7:18 a.go:9:24 b.go:3:21 b.go:5:18"
+	if got := strings.Join(refs, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	refs, err = test_references(t, map[string]string{
+		"a.go": `package p
+
+type S struct{ n int }
+
+func f() S {
+	n# := 1
+	return S{n: n}
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "a.go:6:2 a.go:7:14"
+	if got := strings.Join(refs, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
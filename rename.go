@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"unicode/utf8"
+)
+
+//-------------------------------------------------------------------------
+// Rename
+//
+// Renames locals and package-level declarations of the current package. The
+// references are the ones found by 'references', the rename is refused if
+// any of them would refer to another declaration afterwards or if another
+// identifier would start referring to the renamed declaration. Keys of struct
+// literals are field names and aren't renamed, the rename is refused if the
+// type of a literal with the key isn't known to be a struct, a map or an array.
+//-------------------------------------------------------------------------
+
+// edits of a single file, offsets are in bytes
+type file_edits struct {
+	Filename string
+	Edits    []text_edit
+}
+
+// Returns the edits renaming the identifier at the 'cursor' to 'name', grouped
+// by files. Offsets of the edits of the current file are in the 'file'
+// buffer, the ones of the other files are in the files on the disk.
+func (c *auto_complete_context) rename(file []byte, filename string, cursor int, name string) ([]file_edits, error) {
+	if !is_identifier(name) || name == "_" {
+		return nil, fmt.Errorf("%q is not a valid identifier", name)
+	}
+	old := ident_at_offset(file, cursor)
+	if old == "" {
+		return nil, errors.New("no identifier at the cursor")
+	}
+
+	refs, kind, keys, err := c.find_references(file, filename, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != 0 {
+		// a field name must be left alone, a map key must be renamed
+		return nil, fmt.Errorf("%q is a key of a composite literal of an unknown type at %s", old, keys[0])
+	}
+	switch kind {
+	case ref_imported:
+		return nil, fmt.Errorf("%q is declared in another package", old)
+	case ref_package_name:
+		return nil, errors.New("renaming of imported packages is not supported")
+	case ref_package:
+		if ast.IsExported(old) {
+			return nil, fmt.Errorf("%q is exported, renaming it would break the importers of the package", old)
+		}
+	}
+	if name == old {
+		return nil, nil
+	}
+	if err := c.rename_conflict(file, filename, refs, kind, old, name); err != nil {
+		return nil, err
+	}
+
+	// references are sorted by files
+	var edits []file_edits
+	for _, ref := range refs {
+		if len(edits) == 0 || edits[len(edits)-1].Filename != ref.Filename {
+			edits = append(edits, file_edits{Filename: ref.Filename})
+		}
+		e := &edits[len(edits)-1]
+		e.Edits = append(e.Edits, text_edit{ref.Offset, len(old), name})
+	}
+	return edits, nil
+}
+
+func is_identifier(name string) bool {
+	if name == "" || token.Lookup(name).IsKeyword() {
+		return false
+	}
+	for i, r := range name {
+		if !is_ident_rune(r) || (i == 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the identifier which contains the 'cursor' or ends right before it
+func ident_at_offset(file []byte, cursor int) string {
+	end := ident_end(file, cursor)
+	if end == -1 {
+		return ""
+	}
+	beg := end
+	for beg > 0 {
+		r, n := utf8.DecodeLastRune(file[:beg])
+		if !is_ident_rune(r) {
+			break
+		}
+		beg -= n
+	}
+	return string(file[beg:end])
+}
+
+// Checks that renaming the references 'refs' from 'old' to 'name' doesn't
+// change the meaning of the code.
+func (c *auto_complete_context) rename_conflict(file []byte, filename string, refs []token.Position, kind ref_kind, old, name string) error {
+	conflict := func(d *decl) error {
+		if d.pos.IsValid() {
+			return fmt.Errorf("renaming %q to %q conflicts with the declaration at %s", old, name, c.decl_position(d))
+		}
+		return fmt.Errorf("renaming %q to %q conflicts with %q", old, name, name)
+	}
+
+	// 'name' declared in a local scope which is the same as the one of the
+	// renamed declaration or is nested in it would take over the reference
+	for _, ref := range refs {
+		if ref.Filename != filename {
+			continue
+		}
+		c.process_file(file, filename, ref.Offset+len(old))
+		for s := c.current.scope; s != nil && s != c.current.filescope; s = s.parent {
+			if d, ok := s.entities[name]; ok {
+				return conflict(d)
+			}
+			if _, ok := s.entities[old]; ok {
+				break
+			}
+		}
+	}
+
+	if kind == ref_package {
+		if d := c.pkg.entities[name]; d != nil {
+			return conflict(d)
+		}
+		if d := c.current.filescope.entities[name]; d != nil {
+			return conflict(d)
+		}
+		for _, other := range c.others {
+			if d := other.filescope.entities[name]; d != nil {
+				return conflict(d)
+			}
+		}
+	}
+
+	// identifiers which would start referring to the renamed declaration or
+	// would be declared in the same scope
+	fset := token.NewFileSet()
+	for _, f := range c.package_files() {
+		var offsets []int
+		for _, ref := range refs {
+			if ref.Filename == f.name {
+				offsets = append(offsets, ref.Offset)
+			}
+		}
+		if kind == ref_local && len(offsets) == 0 {
+			continue
+		}
+		ast_file := parse_package_file(fset, f)
+		if ast_file == nil {
+			continue
+		}
+		var id *ast.Ident
+		if kind == ref_local {
+			id = local_rename_conflict(ast_file, fset, offsets[0], name)
+		} else {
+			// locals of the current file have been checked above
+			id = package_rename_conflict(ast_file, fset, offsets, name, f.name != filename)
+		}
+		if id != nil {
+			return fmt.Errorf("renaming %q to %q conflicts with %q at %s", old, name, name, ident_position(fset, id))
+		}
+	}
+	return nil
+}
+
+// Returns an identifier 'name' which would refer to the local the reference
+// at 'offset' refers to if it was renamed, or which is declared in the same
+// scope as the local, nil if there is no such identifier.
+func local_rename_conflict(file *ast.File, fset *token.FileSet, offset int, name string) *ast.Ident {
+	ref := ident_at(file, fset, offset)
+	if ref == nil || ref.Obj == nil {
+		return nil
+	}
+	scope := local_scope(file, ref.Obj.Pos())
+	if scope == nil {
+		return nil
+	}
+	unresolved := make(map[*ast.Ident]bool)
+	for _, id := range file.Unresolved {
+		unresolved[id] = true
+	}
+
+	var found *ast.Ident
+	ast.Inspect(scope, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if found != nil || !ok || id.Name != name {
+			return found == nil
+		}
+		switch {
+		case id.Obj == nil && !unresolved[id]:
+			// a selector, a field or a method name
+		case id.Obj != nil && scope.Pos() <= id.Obj.Pos() && id.Obj.Pos() < scope.End():
+			// declared within the scope, it's fine unless it's in a
+			// nested one
+			if local_scope(file, id.Obj.Pos()) == scope {
+				found = id
+			}
+		case id.Pos() > ref.Obj.Pos():
+			// refers to an outer declaration, the package block or the
+			// universe
+			found = id
+		}
+		return found == nil
+	})
+	return found
+}
+
+// Returns the innermost node which opens the scope containing the declaration
+// at 'pos', e.g. a block or a function. Parameters of a function and the
+// locals of its body are in the same scope, it's the function.
+func local_scope(file *ast.File, pos token.Pos) ast.Node {
+	var scope ast.Node
+	var body *ast.BlockStmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || pos < n.Pos() || pos >= n.End() {
+			return false
+		}
+		switch t := n.(type) {
+		case *ast.FuncDecl:
+			scope, body = n, t.Body
+		case *ast.FuncLit:
+			scope, body = n, t.Body
+		case *ast.BlockStmt:
+			if t != body {
+				scope = n
+			}
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt,
+			*ast.SwitchStmt, *ast.TypeSwitchStmt,
+			*ast.CaseClause, *ast.CommClause:
+			scope = n
+		}
+		return true
+	})
+	return scope
+}
+
+// Returns an identifier 'name' which refers to the universe, it would refer
+// to the renamed package-level declaration instead. If 'locals' is true,
+// also returns a local 'name' declared in any of the top-level declarations
+// containing the references at 'offsets', it might shadow the declaration.
+func package_rename_conflict(file *ast.File, fset *token.FileSet, offsets []int, name string, locals bool) *ast.Ident {
+	for _, id := range file.Unresolved {
+		if id.Name == name {
+			return id
+		}
+	}
+	if !locals {
+		return nil
+	}
+	for _, decl := range file.Decls {
+		beg, end := fset.Position(decl.Pos()).Offset, fset.Position(decl.End()).Offset
+		contains := false
+		for _, off := range offsets {
+			if beg <= off && off < end {
+				contains = true
+			}
+		}
+		if !contains {
+			continue
+		}
+		var found *ast.Ident
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name && id.Obj != nil && file.Scope.Lookup(name) != id.Obj {
+				found = id
+			}
+			return found == nil
+		})
+		if found != nil {
+			return found
+		}
+	}
+	return nil
+}
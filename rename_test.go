@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func test_rename(t *testing.T, files map[string]string, name string) (string, error) {
+	f, cleanup := write_test_package(t, files)
+	defer cleanup()
+	edits, err := server_rename(f.data, f.filename, f.cursor, name, test_context())
+	var out []string
+	for _, fe := range edits {
+		for _, e := range fe.Edits {
+			out = append(out, fmt.Sprintf("%s:%d:%d:%s", fe.Filename[strings.LastIndex(fe.Filename, "/")+1:], e.Offset, e.Length, e.Text))
+		}
+	}
+	return strings.Join(out, " "), err
+}
+
+func TestRename(t *testing.T) {
+	got, err := test_rename(t, map[string]string{
+		"a.go": "package p\n\nvar count# int\n\nfunc f() { count++ }\n",
+		"b.go": "package p\n\nfunc g() { count++ }\n",
+	}, "total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.go:15:5:total a.go:37:5:total b.go:22:5:total"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// the keys of struct literals are field names
+	got, err = test_rename(t, map[string]string{
+		"a.go": "package p\n\ntype S struct{ n int }\n\nfunc f() S {\n\tn# := 1\n\treturn S{n: n}\n}\n",
+	}, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "a.go:49:1:m a.go:69:1:m"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	got, err = test_rename(t, map[string]string{
+		"a.go": "package p\n\ntype S struct{ count int }\n\nvar count# = 1\n\nvar s = S{count: count}\n",
+		"b.go": "package p\n\nvar m = map[int]S{count: {count: count}}\n",
+	}, "total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "a.go:43:5:total a.go:71:5:total b.go:29:5:total b.go:44:5:total"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRenameConflict(t *testing.T) {
+	for _, src := range []string{
+		// declared in the same scope
+		"package p\n\nfunc f() {\n\ta# := 1\n\tb := 2\n\t_, _ = a, b\n}\n",
+		// the renamed declaration would capture a reference
+		"package p\n\nvar b int\n\nfunc f(a# int) int { return a + b }\n",
+		// an inner declaration would shadow the renamed one
+		"package p\n\nfunc f(a# int) {\n\tfor b := 0; b < a; b++ {\n\t}\n}\n",
+	} {
+		got, err := test_rename(t, map[string]string{"a.go": src}, "b")
+		if err == nil || !strings.Contains(err.Error(), "conflicts") {
+			t.Errorf("%q: got %q, %v, want a conflict", src, got, err)
+		}
+	}
+}
+
+func TestRenameRefused(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"package p\n\nfunc Foo#() {}\n", "exported"},
+		// struct fields, methods and interface methods
+		{"package p\n\ntype T struct{ count# int }\n\nfunc f(t T) int { return t.count }\n", "members"},
+		{"package p\n\ntype T int\n\nfunc (T) close#() {}\n\nfunc f(t T) { t.close() }\n", "members"},
+		{"package p\n\ntype I interface{ close#() }\n\nfunc f(i I) { i.close() }\n", "members"},
+		// the key may be a field name
+		{"package p\n\nimport \"x\"\n\nfunc f(n# int) x.T { return x.T{n: n} }\n", "unknown type"},
+	} {
+		got, err := test_rename(t, map[string]string{"a.go": test.src}, "other")
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got %q, %v, want an error about %s", test.src, got, err, test.err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"go/build"
+	"go/token"
 	"log"
 	"path/filepath"
 	"reflect"
@@ -17,6 +18,7 @@ type daemon struct {
 	autocomplete *auto_complete_context
 	pkgcache     package_cache
 	declcache    *decl_cache
+	pkgindex     *package_index
 	context      package_lookup_context
 }
 
@@ -38,19 +40,9 @@ var g_daemon *daemon
 // Corresponding client_* functions are autogenerated by goremote.
 //-------------------------------------------------------------------------
 
-func server_auto_complete(file []byte, filename string, cursor int, context_packed go_build_context) (c []candidate, d int) {
-	context := unpack_build_context(&context_packed)
-	defer func() {
-		if err := recover(); err != nil {
-			print_backtrace(err)
-			c = []candidate{
-				{"PANIC", "PANIC", decl_invalid, "panic"},
-			}
-
-			// drop cache
-			g_daemon.drop_cache()
-		}
-	}()
+// Updates the build context of the daemon for a request about 'filename',
+// drops all the caches if the context has changed.
+func server_update_context(filename string, context package_lookup_context) {
 	// TODO: Probably we don't care about comparing all the fields, checking GOROOT and GOPATH
 	// should be enough.
 	if !reflect.DeepEqual(g_daemon.context.Context, context.Context) {
@@ -90,6 +82,22 @@ func server_auto_complete(file []byte, filename string, cursor int, context_pack
 			log.Printf("Go project path not found: %s", err)
 		}
 	}
+}
+
+func server_auto_complete(file []byte, filename string, cursor int, context_packed go_build_context) (c []candidate, d int) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if err := recover(); err != nil {
+			print_backtrace(err)
+			c = []candidate{
+				{Name: "PANIC", Type: "PANIC", Class: decl_invalid, Package: "panic"},
+			}
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
 	if *g_debug {
 		var buf bytes.Buffer
 		log.Printf("Got autocompletion request for '%s'\n", filename)
@@ -107,6 +115,9 @@ func server_auto_complete(file []byte, filename string, cursor int, context_pack
 			log.Println("-------------------------------------------------------")
 		}
 	}
+	if g_config.UnimportedPackages {
+		g_daemon.pkgindex.refresh(&g_daemon.context, filename)
+	}
 	candidates, d := g_daemon.autocomplete.apropos(file, filename, cursor)
 	if *g_debug {
 		log.Printf("Offset: %d\n", d)
@@ -124,6 +135,172 @@ func server_auto_complete(file []byte, filename string, cursor int, context_pack
 	return candidates, d
 }
 
+func server_implement_stubs(file []byte, filename, typ, iface string, context_packed go_build_context) (stubs string, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			stubs, err = "", fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got stubs request for '%s': %s implements %s\n", filename, typ, iface)
+	}
+	return g_daemon.autocomplete.implement_stubs(file, filename, typ, iface)
+}
+
+func server_definition(file []byte, filename string, cursor int, context_packed go_build_context) (pos token.Position, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			pos, err = token.Position{}, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got definition request for '%s' at %d\n", filename, cursor)
+	}
+	return g_daemon.autocomplete.definition(file, filename, cursor)
+}
+
+func server_hover(file []byte, filename string, cursor int, context_packed go_build_context) (h hover, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			h, err = hover{}, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got hover request for '%s' at %d\n", filename, cursor)
+	}
+	return g_daemon.autocomplete.hover(file, filename, cursor)
+}
+
+func server_signature_help(file []byte, filename string, cursor int, context_packed go_build_context) (sig signature, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			sig, err = signature{}, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got signature help request for '%s' at %d\n", filename, cursor)
+	}
+	return g_daemon.autocomplete.signature_help(file, filename, cursor)
+}
+
+func server_resolve_candidate(file []byte, filename string, cursor int, name string, context_packed go_build_context) (c candidate, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			c, err = candidate{}, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got resolve request for '%s' at %d: %s\n", filename, cursor, name)
+	}
+	return g_daemon.autocomplete.resolve_candidate(file, filename, cursor, name)
+}
+
+func server_references(file []byte, filename string, cursor int, context_packed go_build_context) (refs []token.Position, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			refs, err = nil, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got references request for '%s' at %d\n", filename, cursor)
+	}
+	return g_daemon.autocomplete.references(file, filename, cursor)
+}
+
+func server_symbols(file []byte, filename string, context_packed go_build_context) (symbols []symbol, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			symbols, err = nil, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got symbols request for '%s'\n", filename)
+	}
+	return g_daemon.autocomplete.symbols(file, filename)
+}
+
+func server_rename(file []byte, filename string, cursor int, name string, context_packed go_build_context) (edits []file_edits, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			edits, err = nil, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got rename request for '%s' at %d: %s\n", filename, cursor, name)
+	}
+	return g_daemon.autocomplete.rename(file, filename, cursor, name)
+}
+
+func server_diagnostics(file []byte, filename string, cursor int, context_packed go_build_context) (diags []diagnostic, err error) {
+	context := unpack_build_context(&context_packed)
+	defer func() {
+		if e := recover(); e != nil {
+			print_backtrace(e)
+			diags, err = nil, fmt.Errorf("panic: %v", e)
+
+			// drop cache
+			g_daemon.drop_cache()
+		}
+	}()
+	server_update_context(filename, context)
+	if *g_debug {
+		log.Printf("Got diagnostics request for '%s' at %d\n", filename, cursor)
+	}
+	if g_config.UnimportedPackages {
+		// fixes of undefined packages come from the index
+		g_daemon.pkgindex.refresh(&g_daemon.context, filename)
+	}
+	return g_daemon.autocomplete.diagnostics(file, filename, cursor), nil
+}
+
 //func server_close(notused int) int {
 //	g_daemon.close()
 //	return 0
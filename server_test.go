@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//-------------------------------------------------------------------------
+// Helpers for the tests of the queries
+//
+// A package is written to a temporary directory and the queries are made
+// through the server_* functions, the same way the exported functions make
+// them. The golden tests in _testing cover the autocompletion.
+//-------------------------------------------------------------------------
+
+// the file being edited, the cursor is marked with '#' in its source
+type test_file struct {
+	data     []byte
+	filename string
+	cursor   int
+}
+
+// Writes the files of a package to a temporary directory, names of the files
+// may have directories in them, e.g. "sub/a.go". '#' is removed
+// from the sources. Returns the file which has the cursor in it and the
+// function removing the directory.
+func write_test_package(t *testing.T, files map[string]string) (test_file, func()) {
+	if g_daemon == nil {
+		g_daemon = newDaemon()
+	}
+	dir, err := ioutil.TempDir("", "gocode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the package lookup resolves symlinks, e.g. of the temporary directory
+	if d, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = d
+	}
+
+	var current test_file
+	for name, src := range files {
+		data := []byte(src)
+		filename := filepath.Join(dir, name)
+		if i := bytes.IndexByte(data, '#'); i != -1 {
+			data = append(data[:i:i], data[i+1:]...)
+			current = test_file{data, filename, i}
+		}
+		err := os.MkdirAll(filepath.Dir(filename), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(filename, data, 0644)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return current, func() { os.RemoveAll(dir) }
+}
+
+func test_context() go_build_context {
+	return pack_build_context(&build.Default)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+//-------------------------------------------------------------------------
+// Signature help
+//
+// Shows the signature of the function being called while the cursor is
+// inside of the call parentheses.
+//-------------------------------------------------------------------------
+
+type signature struct {
+	// name of the callee as it's written in the call, e.g.: "fmt.Println"
+	Name string
+
+	// type of the callee, e.g.: "func(a ...interface{}) (n int, err error)"
+	Type string
+
+	// parameters one by one, e.g.: "a ...interface{}", a parameter has no
+	// name if the function doesn't name its parameters
+	Params []string
+
+	// index of the parameter the cursor is at, arguments past the variadic
+	// parameter belong to it
+	Active int
+}
+
+// Returns the signature of the innermost call enclosing the 'cursor'.
+func (c *auto_complete_context) signature_help(file []byte, filename string, cursor int) (signature, error) {
+	c.process_file(file, filename, cursor)
+
+	iter := new_token_iterator(file, cursor)
+	if len(iter.tokens) == 0 {
+		return signature{}, errors.New("not in a function call")
+	}
+	commas, ok := skip_to_call_lparen(&iter)
+	if !ok {
+		return signature{}, errors.New("not in a function call")
+	}
+	callee := iter.extract_go_expr()
+	if callee == "" {
+		return signature{}, errors.New("not in a function call")
+	}
+	expr, err := parser.ParseExpr(callee)
+	if err != nil {
+		return signature{}, errors.New("not in a function call")
+	}
+	t, s, is_type := infer_type(expr, c.current.scope, -1)
+	if t == nil || is_type {
+		return signature{}, errors.New("callee is not a function")
+	}
+	t, _ = advance_to_type(func_predicate, t, s)
+	ft, ok := t.(*ast.FuncType)
+	if !ok {
+		return signature{}, errors.New("callee is not a function")
+	}
+
+	canonical_aliases := new_out_buffers(c).canonical_aliases
+	var buf bytes.Buffer
+	pretty_print_type_expr(&buf, ft, canonical_aliases)
+	sig := signature{
+		Name:   callee,
+		Type:   buf.String(),
+		Active: commas,
+	}
+	variadic := false
+	if ft.Params != nil {
+		for _, field := range ft.Params.List {
+			var tbuf bytes.Buffer
+			pretty_print_type_expr(&tbuf, field.Type, canonical_aliases)
+			_, variadic = field.Type.(*ast.Ellipsis)
+			if len(field.Names) == 0 {
+				sig.Params = append(sig.Params, tbuf.String())
+				continue
+			}
+			for _, name := range field.Names {
+				if name.Name == "?" {
+					// unnamed parameter from the export data
+					sig.Params = append(sig.Params, tbuf.String())
+					continue
+				}
+				sig.Params = append(sig.Params, name.Name+" "+tbuf.String())
+			}
+		}
+	}
+	if variadic && sig.Active >= len(sig.Params) {
+		sig.Active = len(sig.Params) - 1
+	}
+	return sig, nil
+}
+
+// Moves the iterator back to the '(' of the call the cursor is in, returns
+// the number of commas between them, i.e. the index of the argument.
+func skip_to_call_lparen(iter *token_iterator) (int, bool) {
+	commas := 0
+	for {
+		switch iter.token().tok {
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if !iter.skip_to_balanced_pair() {
+				return 0, false
+			}
+		case token.COMMA:
+			commas++
+		case token.LPAREN:
+			return commas, true
+		case token.LBRACK, token.LBRACE, token.SEMICOLON:
+			return 0, false
+		}
+		if !iter.go_back() {
+			return 0, false
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	src := `package p
+
+type T struct{}
+
+func (T) Write(p []byte, off int) (int, error) { return 0, nil }
+
+func logf(format string, args ...interface{}) {}
+
+func add(int, int) int { return 0 }
+
+func f(t T, cb func(n int) bool) {
+	%s
+}
+`
+	for _, test := range []struct {
+		code, name, typ, params string
+		active                  int
+	}{
+		{"logf(#", "logf", "func(format string, args ...interface{})", "format string; args ...interface{}", 0},
+		// arguments past the variadic parameter belong to it
+		{"logf(\"%%d\", 1, #", "logf", "func(format string, args ...interface{})", "format string; args ...interface{}", 1},
+		{"logf(\"(\", #", "logf", "func(format string, args ...interface{})", "format string; args ...interface{}", 1},
+		// the innermost call
+		{"logf(\"x\", add(1, #), 2)", "add", "func(int, int) int", "int; int", 1},
+		{"t.Write(nil, #", "t.Write", "func(p []byte, off int) (int, error)", "p []byte; off int", 1},
+		// function-typed variables
+		{"cb(#", "cb", "func(n int) bool", "n int", 0},
+	} {
+		f, cleanup := write_test_package(t, map[string]string{"a.go": fmt.Sprintf(src, test.code)})
+		sig, err := server_signature_help(f.data, f.filename, f.cursor, test_context())
+		cleanup()
+		if err != nil {
+			t.Errorf("%s: %v", test.code, err)
+			continue
+		}
+		params := strings.Join(sig.Params, "; ")
+		if sig.Name != test.name || sig.Type != test.typ || params != test.params || sig.Active != test.active {
+			t.Errorf("%s: got %s %s (%s) %d, want %s %s (%s) %d", test.code,
+				sig.Name, sig.Type, params, sig.Active, test.name, test.typ, test.params, test.active)
+		}
+	}
+}
+
+func TestSignatureHelpOutsideCall(t *testing.T) {
+	f, cleanup := write_test_package(t, map[string]string{
+		"a.go": "package p\n\nfunc add(a, b int) int { return 0 }\n\nvar x = add(1, 2)#\n",
+	})
+	defer cleanup()
+	if sig, err := server_signature_help(f.data, f.filename, f.cursor, test_context()); err == nil {
+		t.Errorf("got %+v, want an error", sig)
+	}
+}
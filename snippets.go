@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+)
+
+//-------------------------------------------------------------------------
+// Snippets
+//
+// Completion candidates which expand into code templates. Templates use the
+// common ${N:text} tab-stop syntax, $0 marks the final cursor position.
+//-------------------------------------------------------------------------
+
+// escapes the text so that it can be used inside of a ${N:text} placeholder
+func snippet_escape(text string) string {
+	var buf bytes.Buffer
+	for _, r := range text {
+		switch r {
+		case '$', '}', '\\':
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func snippet_placeholder(n int, text string) string {
+	return fmt.Sprintf("${%d:%s}", n, snippet_escape(text))
+}
+
+// Returns a textual representation of the zero value for the type expression
+// 'e' which makes sense in the scope 's'. Composite types are represented by
+// an empty composite literal, e.g.: "T{}".
+func zero_value(e ast.Expr, s *scope, canonical_aliases map[string]string) string {
+	switch t := e.(type) {
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType,
+		*ast.InterfaceType, *ast.Ellipsis:
+		return "nil"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "nil"
+		}
+	case *ast.Ident, *ast.SelectorExpr:
+		d := type_to_decl(e, s)
+		if d == nil || d.class != decl_type {
+			return "nil"
+		}
+		if d.scope == g_universe_scope {
+			return builtin_zero_value(d.name)
+		}
+		if d.is_visited() {
+			return "nil"
+		}
+		d.set_visited()
+		defer d.clear_visited()
+
+		switch d.typ.(type) {
+		case *ast.StructType, *ast.ArrayType:
+			// composite types are initialized with an empty literal,
+			// note that slices are handled below
+		default:
+			v := zero_value(d.typ, d.scope, canonical_aliases)
+			if !strings.HasSuffix(v, "{}") {
+				return v
+			}
+		}
+		if at, ok := d.typ.(*ast.ArrayType); ok && at.Len == nil {
+			return "nil"
+		}
+	}
+
+	var buf bytes.Buffer
+	pretty_print_type_expr(&buf, e, canonical_aliases)
+	return buf.String() + "{}"
+}
+
+func builtin_zero_value(name string) string {
+	switch name {
+	case "bool":
+		return "false"
+	case "string":
+		return `""`
+	case "error":
+		return "nil"
+	}
+	return "0"
+}
+
+func is_error_type(e ast.Expr, s *scope) bool {
+	d := type_to_decl(e, s)
+	return d != nil && d.scope == g_universe_scope && d.name == "error"
+}
+
+func (b *out_buffers) append_snippet(p, name, display, snippet string) {
+	score, matched := b.matcher.match(name, p)
+	if !matched {
+		return
+	}
+	b.candidates = append(b.candidates, candidate{
+		Name:    name,
+		Type:    display,
+		Class:   decl_snippet,
+		Snippet: snippet,
+		Score:   score_package + score,
+	})
+}
+
+// Proposes statement templates, the function is called only when the cursor
+// is at the beginning of a statement inside of a function body.
+func (c *auto_complete_context) get_statement_snippet_candidates(partial string, b *out_buffers) {
+	c.append_iferr_snippet(partial, b)
+	c.append_range_snippets(partial, b)
+	c.append_type_switch_snippets(partial, b)
+}
+
+// iferr: "if err != nil { return ..., err }", where "..." are zero values of the
+// enclosing function's result types
+func (c *auto_complete_context) append_iferr_snippet(partial string, b *out_buffers) {
+	functype := c.current.functype
+	if functype == nil || c.current.scope.lookup("err") == nil {
+		return
+	}
+
+	var values, display []string
+	n := 1
+	if functype.Results != nil {
+		for _, field := range functype.Results.List {
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for i := 0; i < count; i++ {
+				if is_error_type(field.Type, c.current.scope) {
+					values = append(values, "err")
+					display = append(display, "err")
+					continue
+				}
+				zero := zero_value(field.Type, c.current.scope, b.canonical_aliases)
+				values = append(values, snippet_placeholder(n, zero))
+				display = append(display, zero)
+				n++
+			}
+		}
+	}
+
+	ret := "return"
+	if len(values) > 0 {
+		ret += " " + strings.Join(values, ", ")
+	}
+	disp := "return"
+	if len(display) > 0 {
+		disp += " " + strings.Join(display, ", ")
+	}
+	b.append_snippet(partial, "iferr",
+		"if err != nil { "+disp+" }",
+		"if err != nil {\n\t"+ret+"\n}\n$0")
+}
+
+// calls 'do' for every local variable visible at the cursor, inner scopes
+// first, shadowed variables are skipped
+func (c *auto_complete_context) foreach_local_var(do func(d *decl)) {
+	seen := make(map[string]bool)
+	for s := c.current.scope; s != nil && s != c.current.filescope; s = s.parent {
+		names := make([]string, 0, len(s.entities))
+		for name := range s.entities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			d := s.entities[name]
+			if seen[name] || d.class != decl_var || strings.HasPrefix(name, "$") {
+				continue
+			}
+			seen[name] = true
+			do(d)
+		}
+	}
+}
+
+// forr: "for k, v := range x {}", proposed for every local variable which can
+// be ranged over, key and value names depend on the type of the variable
+func (c *auto_complete_context) append_range_snippets(partial string, b *out_buffers) {
+	found := false
+	c.foreach_local_var(func(d *decl) {
+		t, s := d.infer_type()
+		if t == nil {
+			return
+		}
+		t, _ = advance_to_type(range_predicate, t, s)
+		var vars []string
+		switch t.(type) {
+		case *ast.Ident, *ast.ArrayType, *ast.Ellipsis:
+			vars = []string{"i", "v"}
+		case *ast.MapType:
+			vars = []string{"k", "v"}
+		case *ast.ChanType:
+			vars = []string{"v"}
+		default:
+			return
+		}
+
+		found = true
+		placeholders := make([]string, len(vars))
+		for i, v := range vars {
+			placeholders[i] = snippet_placeholder(i+1, v)
+		}
+		b.append_snippet(partial, "forr",
+			"for "+strings.Join(vars, ", ")+" := range "+d.name,
+			"for "+strings.Join(placeholders, ", ")+" := range "+d.name+" {\n\t$0\n}")
+	})
+	if !found {
+		b.append_snippet(partial, "forr",
+			"for k, v := range expr",
+			"for ${1:k}, ${2:v} := range ${3:expr} {\n\t$0\n}")
+	}
+}
+
+// tsw: "switch v := x.(type) {}", proposed for every local variable of an
+// interface type
+func (c *auto_complete_context) append_type_switch_snippets(partial string, b *out_buffers) {
+	found := false
+	c.foreach_local_var(func(d *decl) {
+		t, s := d.infer_type()
+		if t == nil {
+			return
+		}
+		t, _ = advance_to_type(struct_interface_predicate, t, s)
+		if _, ok := t.(*ast.InterfaceType); !ok {
+			return
+		}
+
+		found = true
+		b.append_snippet(partial, "tsw",
+			"switch v := "+d.name+".(type)",
+			"switch ${1:v} := "+d.name+".(type) {\ncase ${2:T}:\n\t$0\n}")
+	})
+	if !found {
+		b.append_snippet(partial, "tsw",
+			"switch v := x.(type)",
+			"switch ${1:v} := ${2:x}.(type) {\ncase ${3:T}:\n\t$0\n}")
+	}
+}
+
+// Generates a parameter name for unnamed parameters (in export data unnamed
+// parameters are called "?"), the name is derived from the type.
+func param_name_from_type(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		name := t.Name
+		if i := strings.LastIndex(name, "!"); i != -1 {
+			name = name[i+1:]
+		}
+		if name == "" || strings.HasPrefix(name, "$") {
+			return "v"
+		}
+		return strings.ToLower(name[:1])
+	case *ast.SelectorExpr:
+		return param_name_from_type(t.Sel)
+	case *ast.StarExpr:
+		return param_name_from_type(t.X)
+	case *ast.ArrayType:
+		return param_name_from_type(t.Elt)
+	case *ast.MapType:
+		return "m"
+	case *ast.ChanType:
+		return "ch"
+	case *ast.FuncType:
+		return "fn"
+	}
+	return "v"
+}
+
+// Returns parameter names of the function type, unnamed and blank parameters
+// get generated names, duplicates are disambiguated with a numeric suffix.
+func func_param_names(ft *ast.FuncType) []string {
+	var names []string
+	if ft.Params == nil {
+		return names
+	}
+	used := make(map[string]int)
+	for _, field := range ft.Params.List {
+		_, variadic := field.Type.(*ast.Ellipsis)
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			name := ""
+			if i < len(field.Names) {
+				name = field.Names[i].Name
+			}
+			if name == "" || name == "?" || name == "_" {
+				if variadic {
+					name = "args"
+				} else {
+					name = param_name_from_type(field.Type)
+				}
+			}
+			if n := used[name]; n > 0 {
+				used[name]++
+				name = fmt.Sprintf("%s%d", name, n)
+			} else {
+				used[name] = 1
+			}
+			if variadic {
+				name += "..."
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Builds a function call snippet, e.g.: "Fprintf(${1:w}, ${2:format}, ${3:a...})$0".
+// If 'recv' is not empty, it is used as the first argument, that's how method
+// expressions are called: "T.Method(${1:t}, ...)".
+func func_call_snippet(name string, ft *ast.FuncType, recv string) string {
+	args := func_param_names(ft)
+	if recv != "" {
+		args = append([]string{recv}, args...)
+	}
+	for i, a := range args {
+		args[i] = snippet_placeholder(i+1, a)
+	}
+	return name + "(" + strings.Join(args, ", ") + ")$0"
+}
+
+// Returns a function call snippet for function declarations and variables of
+// a function type, an empty string for everything else.
+func decl_call_snippet(name string, d *decl, recv string) string {
+	switch d.class {
+	case decl_func, decl_var:
+		ft, ok := d.typ.(*ast.FuncType)
+		if !ok {
+			return ""
+		}
+		if d.class == decl_var {
+			recv = ""
+		}
+		return func_call_snippet(name, ft, recv)
+	}
+	return ""
+}
+
+// fill: "Name: "", Count: 0, Opts: nil,", proposed inside of a struct literal,
+// expands to all the fields which are not present in the literal yet
+func (c *auto_complete_context) append_fill_struct_snippet(cc cursor_context, b *out_buffers) {
+	sd := advance_to_struct_or_interface(cc.decl)
+	if sd == nil {
+		return
+	}
+	st, ok := sd.typ.(*ast.StructType)
+	if !ok || st.Fields == nil {
+		return
+	}
+
+	present := make(map[string]bool, len(cc.struct_keys))
+	for _, k := range cc.struct_keys {
+		present[k] = true
+	}
+	foreign := sd.flags&decl_foreign != 0
+
+	var values, display []string
+	for _, field := range st.Fields.List {
+		names := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(field.Names) == 0 {
+			// embedded field, its name is the name of the type
+			names = append(names, get_type_path(field.Type).name)
+		}
+		for _, name := range names {
+			if name == "" || name == "_" || present[name] || (foreign && !ast.IsExported(name)) {
+				continue
+			}
+			zero := zero_value(field.Type, sd.scope, b.canonical_aliases)
+			values = append(values, name+": "+snippet_placeholder(len(values)+1, zero))
+			display = append(display, name+": "+zero)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	b.append_snippet(cc.partial, "fill",
+		strings.Join(display, ", ")+",",
+		strings.Join(values, ", ")+",$0")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCallSnippets(t *testing.T) {
+	src := `package p
+
+type T struct{}
+
+func (t T) Write(p []byte, _ int) (n int, err error) { return }
+
+func add(a, b int) int { return a + b }
+
+func logf(format string, args ...interface{}) {}
+
+var fn func(int, string, ...byte)
+
+func f(t T) {
+	%s#
+}
+`
+	for _, test := range []struct {
+		typed, name, snippet string
+	}{
+		{"ad", "add", "add(${1:a}, ${2:b})$0"},
+		{"lo", "logf", "logf(${1:format}, ${2:args...})$0"},
+		// unnamed parameters get names from their types
+		{"fn", "fn", "fn(${1:i}, ${2:s}, ${3:args...})$0"},
+		// the blank parameter is unnamed as well
+		{"t.", "Write", "Write(${1:p}, ${2:i})$0"},
+		// method expressions take the receiver first
+		{"T.", "Write", "Write(${1:t}, ${2:p}, ${3:i})$0"},
+	} {
+		f, cleanup := write_test_package(t, map[string]string{"a.go": fmt.Sprintf(src, test.typed)})
+		cands, _ := server_auto_complete(f.data, f.filename, f.cursor, test_context())
+		cleanup()
+		found := false
+		for _, c := range cands {
+			if c.Name == test.name {
+				found = true
+				if c.Snippet != test.snippet {
+					t.Errorf("%s: got %q, want %q", test.typed, c.Snippet, test.snippet)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: %s is not proposed", test.typed, test.name)
+		}
+	}
+}
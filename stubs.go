@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+//-------------------------------------------------------------------------
+// Interface stubs
+//
+// Generates method stubs which a type is missing to implement an interface.
+//-------------------------------------------------------------------------
+
+// Returns method declarations for every method of the interface 'iface' which
+// is missing in the type 'typ', both are type expressions as they would be
+// written in the file, e.g.: "*T" and "io.ReadCloser". A pointer type gets a
+// pointer receiver. Methods of the type's embedded fields count as present.
+// If the type is not a pointer and has some of the methods with pointer
+// receivers, only the pointer type can implement the interface, that's an
+// error.
+func (c *auto_complete_context) implement_stubs(file []byte, filename, typ, iface string) (string, error) {
+	c.process_file(file, filename, len(file))
+	s := c.current.scope
+
+	texpr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return "", fmt.Errorf("invalid type %q: %s", typ, err)
+	}
+	iexpr, err := parser.ParseExpr(iface)
+	if err != nil {
+		return "", fmt.Errorf("invalid interface %q: %s", iface, err)
+	}
+
+	recv := texpr
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	typedecl := type_to_decl(recv, s)
+	if typedecl == nil || typedecl.class != decl_type {
+		return "", fmt.Errorf("type %q not found", typ)
+	}
+	ifacedecl := type_to_decl(iexpr, s)
+	if ifacedecl != nil {
+		ifacedecl = advance_to_struct_or_interface(ifacedecl)
+	}
+	if ifacedecl == nil {
+		return "", fmt.Errorf("interface %q not found", iface)
+	}
+	if _, ok := ifacedecl.typ.(*ast.InterfaceType); !ok {
+		return "", fmt.Errorf("%q is not an interface", iface)
+	}
+
+	canonical_aliases := new_out_buffers(c).canonical_aliases
+	_, isptr := texpr.(*ast.StarExpr)
+	recvname := c.receiver_name(recv)
+	var buf bytes.Buffer
+	for _, m := range interface_methods(ifacedecl) {
+		if d, ptr := typedecl.find_method(m.name); d != nil {
+			if ptr && !isptr {
+				return "", fmt.Errorf("%s has a pointer receiver, only \"*%s\" can implement %q", m.name, typ, iface)
+			}
+			continue
+		}
+		ft, ok := m.typ.(*ast.FuncType)
+		if !ok {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "func (%s %s) %s", recvname, typ, m.name)
+		write_stub_signature(&buf, ft, recvname, canonical_aliases)
+		buf.WriteString(" {\n\tpanic(\"not implemented\")\n}\n")
+	}
+	return buf.String(), nil
+}
+
+// All the methods of a type should have the same receiver name: the one of the
+// methods of the type declared in the package, otherwise it's generated from
+// the type.
+func (c *auto_complete_context) receiver_name(recv ast.Expr) string {
+	if id, ok := recv.(*ast.Ident); ok {
+		fset := token.NewFileSet()
+		for _, f := range c.package_files() {
+			file := parse_package_file(fset, f)
+			if file == nil {
+				continue
+			}
+			for _, d := range file.Decls {
+				fd, ok := d.(*ast.FuncDecl)
+				if !ok || method_of(fd) != id.Name || len(fd.Recv.List[0].Names) == 0 {
+					continue
+				}
+				if name := fd.Recv.List[0].Names[0].Name; name != "_" {
+					return name
+				}
+			}
+		}
+	}
+	return param_name_from_type(recv)
+}
+
+// Returns the names of the parameters of a stub, a parameter with the name of
+// the receiver 'recv' is renamed.
+func stub_param_names(ft *ast.FuncType, recv string) []string {
+	names := func_param_names(ft)
+	used := map[string]bool{recv: true}
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, "...")
+		used[names[i]] = true
+	}
+	for i, name := range names {
+		if name != recv {
+			continue
+		}
+		for j := 1; used[names[i]]; j++ {
+			names[i] = fmt.Sprintf("%s%d", name, j)
+		}
+		used[names[i]] = true
+	}
+	return names
+}
+
+// writes "(name type, ...) (type, ...)", unnamed parameters get names
+// generated from their types
+func write_stub_signature(buf *bytes.Buffer, ft *ast.FuncType, recv string, canonical_aliases map[string]string) {
+	names := stub_param_names(ft, recv)
+	buf.WriteString("(")
+	i := 0
+	if ft.Params != nil {
+		for _, field := range ft.Params.List {
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for j := 0; j < count; j++ {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				buf.WriteString(names[i] + " ")
+				pretty_print_type_expr(buf, field.Type, canonical_aliases)
+				i++
+			}
+		}
+	}
+	buf.WriteString(")")
+
+	var results []string
+	if ft.Results != nil {
+		for _, field := range ft.Results.List {
+			var tbuf bytes.Buffer
+			pretty_print_type_expr(&tbuf, field.Type, canonical_aliases)
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for j := 0; j < count; j++ {
+				results = append(results, tbuf.String())
+			}
+		}
+	}
+	switch len(results) {
+	case 0:
+	case 1:
+		buf.WriteString(" " + results[0])
+	default:
+		buf.WriteString(" (" + strings.Join(results, ", ") + ")")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func test_stubs(t *testing.T, src, typ, iface string) (string, error) {
+	f, cleanup := write_test_package(t, map[string]string{"a.go": src})
+	defer cleanup()
+	return server_implement_stubs(f.data, f.filename, typ, iface, test_context())
+}
+
+func TestStubs(t *testing.T) {
+	src := `package p
+
+type Writer interface {
+	Write(r R) error
+	Close() error
+	Flush()
+}
+
+type R struct{}
+
+func (r R) Flush() {}
+#`
+	got, err := test_stubs(t, src, "R", "Writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the receiver is named like the one of Flush, the parameter is renamed
+	want := "func (r R) Write(r1 R) error {\n\tpanic(\"not implemented\")\n}\n" +
+		"\nfunc (r R) Close() error {\n\tpanic(\"not implemented\")\n}\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, err = test_stubs(t, strings.Replace(src, "func (r R)", "func (w R)", 1), "R", "Writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "func (w R) Write(r R) error {\n\tpanic(\"not implemented\")\n}\n" +
+		"\nfunc (w R) Close() error {\n\tpanic(\"not implemented\")\n}\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStubsPointerReceiver(t *testing.T) {
+	src := `package p
+
+type Closer interface {
+	Close() error
+	Flush()
+}
+
+type E struct{}
+
+func (e *E) Flush() {}
+
+type R struct{ E }
+
+func (r *R) Close() error { return nil }
+#`
+	// the methods with pointer receivers are in the method set of *R only
+	if got, err := test_stubs(t, src, "R", "Closer"); err == nil || !strings.Contains(err.Error(), "pointer receiver") {
+		t.Errorf("got %q, %v, want an error about a pointer receiver", got, err)
+	}
+	got, err := test_stubs(t, src, "*R", "Closer")
+	if err != nil || got != "" {
+		t.Errorf("got %q, %v, want no stubs", got, err)
+	}
+
+	// promoted through an embedded pointer
+	src = strings.Replace(src, "struct{ E }", "struct{ *E }", 1)
+	src = strings.Replace(src, "func (r *R) Close", "func (r R) Close", 1)
+	got, err = test_stubs(t, src, "R", "Closer")
+	if err != nil || got != "" {
+		t.Errorf("embedded by pointer: got %q, %v, want no stubs", got, err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"go/token"
+	"sort"
+)
+
+//-------------------------------------------------------------------------
+// Document symbols
+//
+// Lists the declarations of a file for the structure pane of an editor:
+// top-level declarations with fields and methods of the types as their
+// children. The declarations are the same ones the autocompletion uses.
+//-------------------------------------------------------------------------
+
+type symbol struct {
+	Name  string
+	Class decl_class
+
+	// location of the name and the source range of the whole declaration,
+	// e.g. of a function with its body
+	Pos      token.Position
+	Beg, End token.Position
+
+	// fields and methods of types, methods of interfaces, sorted by their
+	// locations
+	Children []symbol
+}
+
+// Returns the symbols of the file sorted by their locations. The file is
+// parsed from 'data' if it's not nil, otherwise it's read from the disk.
+func (c *auto_complete_context) symbols(data []byte, filename string) ([]symbol, error) {
+	var f *decl_file_cache
+	if data != nil {
+		f = new_decl_file_cache(filename, c.declcache.context)
+		f.process_data(data)
+	} else {
+		f = c.declcache.get_and_update(filename)
+		if f.decls == nil && f.error != nil {
+			return nil, f.error
+		}
+	}
+
+	var symbols []symbol
+	for _, d := range f.decls {
+		if d.class != decl_methods_stub {
+			symbols = append(symbols, decl_symbol(d.name, d))
+			continue
+		}
+		// methods of a type declared in another file of the package
+		for _, m := range d.children {
+			symbols = append(symbols, decl_symbol(d.name+"."+m.name, m))
+		}
+	}
+	sort.Sort(symbols_by_offset(symbols))
+	return symbols, nil
+}
+
+func decl_symbol(name string, d *decl) symbol {
+	s := symbol{
+		Name:  name,
+		Class: d.class,
+		Pos:   d.pos,
+		Beg:   d.beg,
+		End:   d.end,
+	}
+	if d.class != decl_type {
+		return s
+	}
+	for _, c := range d.children {
+		s.Children = append(s.Children, decl_symbol(c.name, c))
+	}
+	sort.Sort(symbols_by_offset(s.Children))
+	return s
+}
+
+type symbols_by_offset []symbol
+
+func (s symbols_by_offset) Len() int           { return len(s) }
+func (s symbols_by_offset) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s symbols_by_offset) Less(i, j int) bool { return s[i].Pos.Offset < s[j].Pos.Offset }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func format_symbols(out *[]string, syms []symbol, indent string) {
+	for _, s := range syms {
+		*out = append(*out, fmt.Sprintf("%s%s %s %d:%d %d:%d-%d:%d", indent, s.Class, s.Name,
+			s.Pos.Line, s.Pos.Column, s.Beg.Line, s.Beg.Column, s.End.Line, s.End.Column))
+		format_symbols(out, s.Children, indent+"  ")
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	src := `package p
+
+import "fmt"
+
+const (
+	A = iota
+	B
+)
+
+var x, y int
+
+type I interface {
+	M()
+}
+
+type T struct {
+	Count, Size int
+	I
+}
+
+func (t *T) Close() error { return nil }
+
+func f() {
+	fmt.Println()
+}
+
+func (T) String() string { return "" }
+`
+	want := `const A 6:2 6:2-6:10
+const B 7:2 7:2-7:3
+var x 10:5 10:1-10:13
+var y 10:8 10:1-10:13
+type I 12:6 12:1-14:2
+  func M 13:2 13:2-13:5
+type T 16:6 16:1-19:2
+  var Count 17:2 17:2-17:17
+  var Size 17:9 17:2-17:17
+  var I 18:2 18:2-18:3
+  func Close 21:13 21:1-21:41
+  func String 27:10 27:1-27:39
+func f 23:6 23:1-25:2`
+
+	// the cursor is not used, it only marks the file
+	f, cleanup := write_test_package(t, map[string]string{"a.go": src + "#"})
+	defer cleanup()
+	for _, test := range []struct {
+		data []byte
+		want string
+	}{
+		// the file on the disk
+		{nil, want},
+		// the unsaved buffer
+		{[]byte(src + "\nfunc g() {}\n"), want + "\nfunc g 29:6 29:1-29:12"},
+	} {
+		syms, err := server_symbols(test.data, f.filename, test_context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		format_symbols(&out, syms, "")
+		if got := strings.Join(out, "\n"); got != test.want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+		}
+	}
+}